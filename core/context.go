@@ -16,7 +16,9 @@ type UserInfoContext interface {
 }
 
 type Context struct {
-	Request   *http.Request
+	Request *http.Request
+	// Body is the raw body of Request, which has been read by server
+	Body      []byte
 	Params    map[string]interface{}
 	TraceId   string
 	Keys      map[string]interface{}
@@ -30,10 +32,20 @@ type Context struct {
 	}
 	Reporter Reporter
 	Action   string
+	// Version and Language are resolved from headers, URL path or body of the request
+	Version  string
+	Language string
 
 	index       int64
 	middlewares []Middleware
 	mu          sync.RWMutex
+
+	// parent carries cancellation and deadline of the request,
+	// cancel release it when request finished
+	parent context.Context
+	cancel context.CancelFunc
+	// spanCtx carries the current span started by StartSpan
+	spanCtx context.Context
 }
 
 // NewContext ...
@@ -43,6 +55,16 @@ func NewContext() *Context {
 	}
 }
 
+// NewRequestContext create Context bound to r.Context()
+// when client disconnects or server shutdown, ctx.Done() will be closed
+// and cancellation reaches every downstream call using ctx
+func NewRequestContext(r *http.Request) *Context {
+	ctx := NewContext()
+	ctx.Request = r
+	ctx.parent, ctx.cancel = context.WithCancel(r.Context())
+	return ctx
+}
+
 type userInfo struct {
 	AppId         int64
 	Uin           string
@@ -79,7 +101,7 @@ func (ctx *userInfoContext) Done() <-chan struct{} {
 }
 
 func (ctx *userInfoContext) Err() error {
-	return ctx.raw().Error
+	return ctx.raw().Err()
 }
 
 func (ctx *userInfoContext) Set(key string, value interface{}) {
@@ -131,22 +153,90 @@ func (ctx *userInfoContext) UserInfo() *userInfo {
 	return ctx.userInfo
 }
 
+// base return the context carrying cancellation of ctx
+// Context created without request falls back to context.Background()
+func (ctx *Context) base() context.Context {
+	if ctx.parent == nil {
+		return context.Background()
+	}
+	return ctx.parent
+}
+
+// SetTimeout set deadline of ctx to now + timeout, the earlier deadline wins
+// SetTimeout should be called before ctx is shared with other goroutines
+func (ctx *Context) SetTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	parent, cancel := context.WithTimeout(ctx.base(), timeout)
+	if prevCancel := ctx.cancel; prevCancel != nil {
+		ctx.cancel = func() {
+			cancel()
+			prevCancel()
+		}
+	} else {
+		ctx.cancel = cancel
+	}
+	ctx.parent = parent
+}
+
+// Cancel cancel ctx and release resources associated with it
+// it is safe to call Cancel multiple times
+func (ctx *Context) Cancel() {
+	if ctx.cancel != nil {
+		ctx.cancel()
+	}
+}
+
+// Fork return a copy of ctx to run the rest of middlewares in another goroutine.
+// The copy has its own Keys, Error and current span, so that it's not changed by ctx and vice versa,
+// and it's canceled with ctx.
+func (ctx *Context) Fork() *Context {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	forked := &Context{
+		Request:     ctx.Request,
+		Body:        ctx.Body,
+		Params:      ctx.Params,
+		TraceId:     ctx.TraceId,
+		LogFields:   ctx.LogFields,
+		Error:       ctx.Error,
+		UserInfo:    ctx.UserInfo,
+		Reporter:    ctx.Reporter,
+		Action:      ctx.Action,
+		Version:     ctx.Version,
+		Language:    ctx.Language,
+		index:       ctx.index,
+		middlewares: ctx.middlewares,
+		parent:      ctx.parent,
+		spanCtx:     ctx.spanCtx,
+	}
+	if ctx.Keys != nil {
+		forked.Keys = make(map[string]interface{}, len(ctx.Keys))
+		for key, value := range ctx.Keys {
+			forked.Keys[key] = value
+		}
+	}
+	return forked
+}
+
 func (ctx *Context) Deadline() (deadline time.Time, ok bool) {
-	// TODO(loganqian)
-	return
+	return ctx.base().Deadline()
 }
 
 func (ctx *Context) Done() <-chan struct{} {
-	// TODO(loganqian)
-	return nil
+	return ctx.base().Done()
 }
 
+// Err return context.Canceled or context.DeadlineExceeded after ctx is done
+// Err does not return ctx.Error, which is the error of request processing
 func (ctx *Context) Err() error {
-	return ctx.Error
+	return ctx.base().Err()
 }
 
 // LoadUserInfo 自动识别 AppId Uin SubAccountUin 并加载至 Context.UserInfo
-// AppId Uin SubAccountUin 支持 string/int/int64，如果是其他格式将返回错误
+// AppId Uin SubAccountUin 支持 string/int/int64/float64/json.Number，如果是其他格式将返回错误
 func (ctx *Context) LoadUserInfo(data map[string]interface{}) error {
 
 	if rawAppId, ok := data["AppId"]; ok {
@@ -185,6 +275,8 @@ func (ctx *Context) LoadUserInfo(data map[string]interface{}) error {
 			ctx.UserInfo.Uin = strconv.Itoa(uin)
 		case int64:
 			ctx.UserInfo.Uin = strconv.Itoa(int(uin))
+		case float64:
+			ctx.UserInfo.Uin = strconv.FormatFloat(uin, 'f', -1, 64)
 		case json.Number:
 			ctx.UserInfo.Uin = uin.String()
 		default:
@@ -202,6 +294,8 @@ func (ctx *Context) LoadUserInfo(data map[string]interface{}) error {
 			ctx.UserInfo.SubAccountUin = strconv.Itoa(subAccountUin)
 		case int64:
 			ctx.UserInfo.SubAccountUin = strconv.Itoa(int(subAccountUin))
+		case float64:
+			ctx.UserInfo.SubAccountUin = strconv.FormatFloat(subAccountUin, 'f', -1, 64)
 		case json.Number:
 			ctx.UserInfo.SubAccountUin = subAccountUin.String()
 		default:
@@ -231,12 +325,17 @@ func (ctx *Context) Get(key string) (interface{}, bool) {
 	return value, exists
 }
 
+// Value lookup key in ctx.Keys first, then in the current span, and then in the request context
 func (ctx *Context) Value(key interface{}) interface{} {
 	if key, ok := key.(string); ok {
-		val, _ := ctx.Get(key)
+		if val, exists := ctx.Get(key); exists {
+			return val
+		}
+	}
+	if val := ctx.spanValue(key); val != nil {
 		return val
 	}
-	return nil
+	return ctx.base().Value(key)
 }
 
 // Use add middleware to request context
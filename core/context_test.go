@@ -1,10 +1,14 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"github.com/google/uuid"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 )
 
 const (
@@ -88,3 +92,66 @@ func TestNewUserInfoContext(t *testing.T) {
 		t.Error("user info not match")
 	}
 }
+
+func TestNewRequestContext(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	reqCtx, cancel := context.WithCancel(r.Context())
+	ctx := NewRequestContext(r.WithContext(reqCtx))
+	defer ctx.Cancel()
+
+	userInfoCtx := NewUserInfoContext(ctx, AppId, Uin, SubAccountUin)
+	if userInfoCtx.Err() != nil {
+		t.Error("context should not be done")
+	}
+
+	cancel()
+	select {
+	case <-userInfoCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("cancellation of request not propagated")
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Err() = %v, want context.Canceled", ctx.Err())
+	}
+}
+
+func TestContext_SetTimeout(t *testing.T) {
+	ctx := NewContext()
+	ctx.SetTimeout(10 * time.Millisecond)
+	defer ctx.Cancel()
+
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("deadline not set")
+	}
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err() = %v, want context.DeadlineExceeded", ctx.Err())
+	}
+}
+
+func TestContext_Fork(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx := NewRequestContext(r)
+	ctx.Set("key", "value")
+	ctx.Action = "DescribeTest"
+
+	forked := ctx.Fork()
+	forked.Set("key", "forked")
+	forked.Error = errors.New("failed")
+	if value, _ := ctx.Get("key"); value != "value" {
+		t.Errorf("Keys of ctx is changed by forked context, value = %v", value)
+	}
+	if ctx.Error != nil {
+		t.Errorf("Error of ctx is changed by forked context, Error = %v", ctx.Error)
+	}
+	if forked.Action != ctx.Action {
+		t.Errorf("Action = %s, want %s", forked.Action, ctx.Action)
+	}
+
+	ctx.Cancel()
+	select {
+	case <-forked.Done():
+	case <-time.After(time.Second):
+		t.Fatal("cancellation of ctx not propagated to forked context")
+	}
+}
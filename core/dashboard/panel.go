@@ -13,6 +13,11 @@ type MountPoint struct {
 	Handler http.Handler
 }
 
+// APIDocumenter provides API document of a version, like framework.Server
+type APIDocumenter interface {
+	APIDocument(version string) ([]byte, error)
+}
+
 type Panel struct {
 	MountPoints []*MountPoint
 }
@@ -65,3 +70,20 @@ func NewPprofPanel() *Panel {
 	})
 	return &Panel{MountPoints: surfaces}
 }
+
+// NewOpenAPIPanel create a Panel serving OpenAPI document at /openapi.json,
+// version of the document is selected by query `Version`
+func NewOpenAPIPanel(documenter APIDocumenter) *Panel {
+	return &Panel{MountPoints: []*MountPoint{{
+		Path: "/openapi.json",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			doc, err := documenter.APIDocument(r.URL.Query().Get("Version"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(doc)
+		}),
+	}}}
+}
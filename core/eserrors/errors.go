@@ -18,17 +18,76 @@ var errorMap = map[string]string{
 	Aaa: Bbb,
 }
 
+// frameworkErrorMap is messages of secondary codes of framework, they are looked up if not in errorMap,
+// so that services can override them by InitErrorMap
+var frameworkErrorMap = map[string]string{
+	InvalidParameterSyntaxCode:                  InvalidParameterSyntaxCodeMessage,
+	InvalidParameterBodyTooLargeCode:            InvalidParameterBodyTooLargeMessage,
+	InvalidParameterExcludedCode:                InvalidParameterExcludedMessage,
+	InvalidParameterValueTypeCode:               InvalidParameterValueTypeMessage,
+	InvalidParameterValueRangeCode:              InvalidParameterValueRangeMessage,
+	InvalidParameterValueTooSmallCode:           InvalidParameterValueTooSmallMessage,
+	InvalidParameterValueTooLargeCode:           InvalidParameterValueTooLargeMessage,
+	InvalidParameterValueLengthCode:             InvalidParameterValueLengthMessage,
+	InvalidParameterValueInvalidFilterCode:      InvalidParameterValueInvalidFilterMessage,
+	InvalidParameterValueInvalidFilterValueCode: InvalidParameterValueInvalidFilterValueMessage,
+	InvalidParameterValueFieldsCompareCode:      InvalidParameterValueFieldsCompareMessage,
+	InvalidParameterValueCompareCode:            InvalidParameterValueCompareMessage,
+	InvalidParameterValueContentCode:            InvalidParameterValueContentMessage,
+	InvalidParameterValueDuplicateCode:          InvalidParameterValueDuplicateMessage,
+	InvalidParameterValueFormatCode:             InvalidParameterValueFormatMessage,
+	InvalidParameterValueEmailCode:              InvalidParameterValueEmailMessage,
+	InvalidParameterValueUrlCode:                InvalidParameterValueUrlMessage,
+	InvalidParameterValueUuidCode:               InvalidParameterValueUuidMessage,
+	InvalidParameterValueIpCode:                 InvalidParameterValueIpMessage,
+	InvalidParameterValueCidrCode:               InvalidParameterValueCidrMessage,
+	InvalidParameterValueMacCode:                InvalidParameterValueMacMessage,
+	InvalidParameterValueHostnameCode:           InvalidParameterValueHostnameMessage,
+	InvalidParameterValueNetAddressCode:         InvalidParameterValueNetAddressMessage,
+	InvalidParameterValueDatetimeCode:           InvalidParameterValueDatetimeMessage,
+	MissingParameterConditionalCode:             MissingParameterConditionalMessage,
+	AuthFailureMissingAuthorizationCode:         AuthFailureMissingAuthorizationMessage,
+	AuthFailureInvalidAuthorizationCode:         AuthFailureInvalidAuthorizationMessage,
+	AuthFailureSecretIdNotFoundCode:             AuthFailureSecretIdNotFoundMessage,
+	AuthFailureSignatureExpireCode:              AuthFailureSignatureExpireMessage,
+	AuthFailureSignatureFailureCode:             AuthFailureSignatureFailureMessage,
+	AuthFailureSignatureReplayedCode:            AuthFailureSignatureReplayedMessage,
+	RequestLimitExceededAppIdCode:               RequestLimitExceededAppIdMessage,
+	RequestLimitExceededIpCode:                  RequestLimitExceededIpMessage,
+	RequestLimitExceededTenantCode:              RequestLimitExceededTenantMessage,
+}
+
 func InitErrorMap(input map[string]string) {
 	for k, v := range input {
 		if _, ok := errorMap[k]; ok {
 			// don't panic
-			fmt.Printf("input error map item override framework, error code: [%s]", k)
+			fmt.Printf("input error map item override registered one, error code: [%s]", k)
 		}
 		errorMap[k] = v
 	}
 	return
 }
 
+// IsRegisteredCode report whether message of secondary code is registered by InitErrorMap
+func IsRegisteredCode(code string) bool {
+	_, ok := errorMap[code]
+	return ok
+}
+
+// IsFrameworkCode report whether secondary code is defined by framework
+func IsFrameworkCode(code string) bool {
+	_, ok := frameworkErrorMap[code]
+	return ok
+}
+
+// messageOf return message of secondary code, which is registered by InitErrorMap or defined by framework
+func messageOf(code string) string {
+	if message, ok := errorMap[code]; ok {
+		return message
+	}
+	return frameworkErrorMap[code]
+}
+
 type baseError struct {
 	Code            string
 	Message         string
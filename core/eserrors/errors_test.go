@@ -0,0 +1,23 @@
+package eserrors
+
+import "testing"
+
+func TestInitErrorMap(t *testing.T) {
+	defer func() { delete(errorMap, InvalidParameterValueEmailCode) }()
+
+	if IsRegisteredCode(InvalidParameterValueEmailCode) || !IsFrameworkCode(InvalidParameterValueEmailCode) {
+		t.Fatal("framework code should not be registered by service")
+	}
+	if _, message := InvalidParameterValueEx(InvalidParameterValueEmailCode, nil).Format(); message == "" {
+		t.Error("message of framework code is missing")
+	}
+
+	// service overrides message of framework code
+	InitErrorMap(map[string]string{InvalidParameterValueEmailCode: "bad email"})
+	if !IsRegisteredCode(InvalidParameterValueEmailCode) {
+		t.Error("code of service is not registered")
+	}
+	if _, message := InvalidParameterValueEx(InvalidParameterValueEmailCode, nil).Format(); message != "bad email" {
+		t.Errorf("message = %q, want message of service", message)
+	}
+}
@@ -0,0 +1,79 @@
+package eserrors
+
+import "strings"
+
+// FieldError is an EsError caused by request field Field, which is a path like `Filters.0.Name`
+type FieldError struct {
+	EsError
+	Field string
+}
+
+// MultiError collects errors of all invalid fields of a request.
+// It formats as the first error, so that clients only reading one error still work.
+type MultiError struct {
+	errs []*FieldError
+	err  error
+}
+
+func NewMultiError() *MultiError {
+	return &MultiError{}
+}
+
+// Append add err of field to me
+func (me *MultiError) Append(field string, err EsError) {
+	me.errs = append(me.errs, &FieldError{EsError: err, Field: field})
+}
+
+// Errors return all field errors in order of appending
+func (me *MultiError) Errors() []*FieldError {
+	return me.errs
+}
+
+func (me *MultiError) Len() int {
+	return len(me.errs)
+}
+
+// ErrorOrNil return me if any error is appended, otherwise nil
+func (me *MultiError) ErrorOrNil() error {
+	if me == nil || len(me.errs) == 0 {
+		return nil
+	}
+	return me
+}
+
+func (me *MultiError) Format() (string, string) {
+	if len(me.errs) == 0 {
+		return InternalError().Format()
+	}
+	return me.errs[0].Format()
+}
+
+func (me *MultiError) Error() string {
+	messages := make([]string, 0, len(me.errs))
+	for _, err := range me.errs {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (me *MultiError) Unwrap() error {
+	if me.err != nil {
+		return me.err
+	}
+	if len(me.errs) == 0 {
+		return nil
+	}
+	return me.errs[0].EsError
+}
+
+func (me *MultiError) Wrap(err error) EsError {
+	me.err = err
+	return me
+}
+
+func (me *MultiError) GetData() interface{} {
+	if len(me.errs) == 0 {
+		return nil
+	}
+	return me.errs[0].GetData()
+}
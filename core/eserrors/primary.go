@@ -19,8 +19,8 @@ func InvalidParameterEx(secondaryCode string, data interface{}) EsError {
 
 	return &baseError{
 		Code:            InvalidParameterCode,
-		Message:         errorMap[secondaryCode],
-		MessageTemplate: errorMap[secondaryCode],
+		Message:         messageOf(secondaryCode),
+		MessageTemplate: messageOf(secondaryCode),
 		SecondaryCode:   secondaryCode,
 		Data:            data,
 	}
@@ -31,8 +31,58 @@ func InvalidParameterValueEx(secondaryCode string, data interface{}) EsError {
 
 	return &baseError{
 		Code:            InvalidParameterValueCode,
-		Message:         errorMap[secondaryCode],
-		MessageTemplate: errorMap[secondaryCode],
+		Message:         messageOf(secondaryCode),
+		MessageTemplate: messageOf(secondaryCode),
+		SecondaryCode:   secondaryCode,
+		Data:            data,
+	}
+}
+
+// InvalidParameterValueWithMessage is the same as InvalidParameterValueEx, except that messageTemplate
+// is given instead of looked up from registered error messages
+func InvalidParameterValueWithMessage(secondaryCode, messageTemplate string, data interface{}) EsError {
+	const InvalidParameterValueCode = "InvalidParameterValue"
+
+	return &baseError{
+		Code:            InvalidParameterValueCode,
+		Message:         messageTemplate,
+		MessageTemplate: messageTemplate,
+		SecondaryCode:   secondaryCode,
+		Data:            data,
+	}
+}
+
+func MissingParameterEx(secondaryCode string, data interface{}) EsError {
+	const MissingParameterCode = "MissingParameter"
+
+	return &baseError{
+		Code:            MissingParameterCode,
+		Message:         messageOf(secondaryCode),
+		MessageTemplate: messageOf(secondaryCode),
+		SecondaryCode:   secondaryCode,
+		Data:            data,
+	}
+}
+
+func AuthFailureEx(secondaryCode string, data interface{}) EsError {
+	const AuthFailureCode = "AuthFailure"
+
+	return &baseError{
+		Code:            AuthFailureCode,
+		Message:         messageOf(secondaryCode),
+		MessageTemplate: messageOf(secondaryCode),
+		SecondaryCode:   secondaryCode,
+		Data:            data,
+	}
+}
+
+func RequestLimitExceededEx(secondaryCode string, data interface{}) EsError {
+	const RequestLimitExceededCode = "RequestLimitExceeded"
+
+	return &baseError{
+		Code:            RequestLimitExceededCode,
+		Message:         messageOf(secondaryCode),
+		MessageTemplate: messageOf(secondaryCode),
 		SecondaryCode:   secondaryCode,
 		Data:            data,
 	}
@@ -80,6 +130,38 @@ func InvalidAction(action string) EsError {
 	}
 }
 
+func UnsupportedVersion(version string) EsError {
+	const (
+		UnsupportedVersionCode    = "UnsupportedVersion"
+		UnsupportedVersionMessage = "The API version `{{.Version}}` requested is not supported."
+	)
+	return &baseError{
+		Code:            UnsupportedVersionCode,
+		Message:         UnsupportedVersionMessage,
+		MessageTemplate: UnsupportedVersionMessage,
+		SecondaryCode:   "",
+		Data:            struct{ Version string }{Version: version},
+	}
+}
+
+func UnauthorizedOperation(action, resource string) EsError {
+	const (
+		UnauthorizedOperationCode    = "UnauthorizedOperation"
+		UnauthorizedOperationMessage = "The request is not authorized to perform `{{.Action}}` " +
+			"on the resource `{{.Resource}}`."
+	)
+	return &baseError{
+		Code:            UnauthorizedOperationCode,
+		Message:         UnauthorizedOperationMessage,
+		MessageTemplate: UnauthorizedOperationMessage,
+		SecondaryCode:   "",
+		Data: struct {
+			Action   string
+			Resource string
+		}{Action: action, Resource: resource},
+	}
+}
+
 func InvalidParameterValue(parameter string, value interface{}) EsError {
 	const (
 		InvalidParameterValueCode    = "InvalidParameterValue"
@@ -96,3 +178,46 @@ func InvalidParameterValue(parameter string, value interface{}) EsError {
 		}{Parameter: parameter, Value: value},
 	}
 }
+
+func ResourceInsufficient() EsError {
+	const (
+		ResourceInsufficientCode    = "ResourceInsufficient"
+		ResourceInsufficientMessage = "The server is too busy to process the request. Retry your request later"
+	)
+	return &baseError{
+		Code:            ResourceInsufficientCode,
+		Message:         ResourceInsufficientMessage,
+		MessageTemplate: "",
+		SecondaryCode:   "",
+		Data:            nil,
+	}
+}
+
+func RequestInProgress(clientToken string) EsError {
+	const (
+		RequestInProgressCode    = "RequestInProgress"
+		RequestInProgressMessage = "The request with ClientToken `{{.ClientToken}}` is still in progress. " +
+			"Retry your request later"
+	)
+	return &baseError{
+		Code:            RequestInProgressCode,
+		Message:         RequestInProgressMessage,
+		MessageTemplate: RequestInProgressMessage,
+		SecondaryCode:   "",
+		Data:            struct{ ClientToken string }{ClientToken: clientToken},
+	}
+}
+
+func RequestTimeout() EsError {
+	const (
+		RequestTimeoutCode    = "RequestTimeout"
+		RequestTimeoutMessage = "The request processing has timed out. Retry your request"
+	)
+	return &baseError{
+		Code:            RequestTimeoutCode,
+		Message:         RequestTimeoutMessage,
+		MessageTemplate: "",
+		SecondaryCode:   "",
+		Data:            nil,
+	}
+}
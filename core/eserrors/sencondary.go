@@ -37,6 +37,96 @@ const (
 	InvalidParameterValueFieldsCompareCode    = "FieldsCompare"
 	InvalidParameterValueFieldsCompareMessage = "`{{.leftField}}` must {{.relation}} `{{.rightField}}`"
 
+	InvalidParameterValueCompareCode    = "Compare"
+	InvalidParameterValueCompareMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"must be {{.relation}} `{{.param}}`."
+
+	InvalidParameterValueContentCode    = "Content"
+	InvalidParameterValueContentMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"must {{.relation}} `{{.param}}`."
+
+	InvalidParameterValueDuplicateCode    = "Duplicate"
+	InvalidParameterValueDuplicateMessage = "The parameter `{{.parameter}}` must not contain duplicate values."
+
+	InvalidParameterValueFormatCode    = "Format"
+	InvalidParameterValueFormatMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueEmailCode    = "Email"
+	InvalidParameterValueEmailMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid email address."
+
+	InvalidParameterValueUrlCode    = "Url"
+	InvalidParameterValueUrlMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueUuidCode    = "Uuid"
+	InvalidParameterValueUuidMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueIpCode    = "Ip"
+	InvalidParameterValueIpMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueCidrCode    = "Cidr"
+	InvalidParameterValueCidrMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueMacCode    = "Mac"
+	InvalidParameterValueMacMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid MAC address."
+
+	InvalidParameterValueHostnameCode    = "Hostname"
+	InvalidParameterValueHostnameMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueNetAddressCode    = "NetAddress"
+	InvalidParameterValueNetAddressMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"is not a valid {{.format}}."
+
+	InvalidParameterValueDatetimeCode    = "Datetime"
+	InvalidParameterValueDatetimeMessage = "The value `{{.value}}` specified in the parameter `{{.parameter}}` " +
+		"does not match the datetime layout `{{.param}}`."
+
+	InvalidParameterExcludedCode    = "Excluded"
+	InvalidParameterExcludedMessage = "The parameter `{{.parameter}}` must not be specified" +
+		"{{if .condition}} {{.condition}}{{end}}."
+
+	MissingParameterConditionalCode    = "Conditional"
+	MissingParameterConditionalMessage = "The request is missing a parameter `{{.parameter}}`, " +
+		"which is required {{.condition}}."
+
+	AuthFailureMissingAuthorizationCode    = "MissingAuthorization"
+	AuthFailureMissingAuthorizationMessage = "The request is missing the header `{{.header}}`."
+
+	AuthFailureInvalidAuthorizationCode    = "InvalidAuthorization"
+	AuthFailureInvalidAuthorizationMessage = "The header `{{.header}}` of the request is malformed: {{.reason}}."
+
+	AuthFailureSecretIdNotFoundCode    = "SecretIdNotFound"
+	AuthFailureSecretIdNotFoundMessage = "The SecretId `{{.secretId}}` is not found."
+
+	AuthFailureSignatureExpireCode    = "SignatureExpire"
+	AuthFailureSignatureExpireMessage = "The signature is expired, the timestamp `{{.timestamp}}` of the request " +
+		"differs from the server time by more than {{.maxSkew}}."
+
+	AuthFailureSignatureFailureCode    = "SignatureFailure"
+	AuthFailureSignatureFailureMessage = "The signature of the request is not valid."
+
+	AuthFailureSignatureReplayedCode    = "SignatureReplayed"
+	AuthFailureSignatureReplayedMessage = "The signed request has been received before."
+
+	RequestLimitExceededAppIdCode    = "AppIdLimitExceeded"
+	RequestLimitExceededAppIdMessage = "The requests of AppId `{{.appId}}` to `{{.action}}` exceed the limit " +
+		"of {{.rate}} per second. Retry your request later."
+
+	RequestLimitExceededIpCode    = "IPLimitExceeded"
+	RequestLimitExceededIpMessage = "The requests from IP `{{.ip}}` to `{{.action}}` exceed the limit " +
+		"of {{.rate}} per second. Retry your request later."
+
+	RequestLimitExceededTenantCode    = "TenantLimitExceeded"
+	RequestLimitExceededTenantMessage = "The requests of AppId `{{.appId}}` exceed the limit " +
+		"of {{.rate}} per second. Retry your request later."
+
 	Aaa = "Aaa"
 	Bbb = "Bbb"
 )
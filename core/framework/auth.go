@@ -0,0 +1,351 @@
+package framework
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"hash"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TC3Algorithm           = "TC3-HMAC-SHA256"
+	AuthorizationHeader    = "Authorization"
+	DefaultTimestampHeader = "X-TC-Timestamp"
+	DefaultMaxSkew         = 5 * time.Minute
+
+	// AuthSecretIdKey is the key of SecretId of the authenticated request in ctx.Keys
+	AuthSecretIdKey = "AuthSecretId"
+
+	tc3Terminator = "tc3_request"
+	tc3KeyPrefix  = "TC3"
+)
+
+// Secret is the secret key of a SecretId and the account it belongs to
+type Secret struct {
+	SecretKey     string
+	AppId         int
+	Uin           string
+	SubAccountUin string
+}
+
+// SecretProvider looks up Secret of SecretId, nil Secret means SecretId is not found
+type SecretProvider interface {
+	GetSecret(ctx context.Context, secretId string) (*Secret, error)
+}
+
+// StaticSecretProvider is a SecretProvider of fixed secrets keyed by SecretId
+type StaticSecretProvider map[string]Secret
+
+func (provider StaticSecretProvider) GetSecret(_ context.Context, secretId string) (*Secret, error) {
+	secret, ok := provider[secretId]
+	if !ok {
+		return nil, nil
+	}
+	return &secret, nil
+}
+
+// ReplayCache remembers signatures of verified requests
+type ReplayCache interface {
+	// Add add key which expires after ttl, and report false if key has been added and not expired
+	Add(key string, ttl time.Duration) bool
+}
+
+type memoryReplayCache struct {
+	mu        sync.Mutex
+	keys      map[string]time.Time
+	nextPurge time.Time
+}
+
+// NewMemoryReplayCache create ReplayCache in memory, which only detects replays to the same process
+func NewMemoryReplayCache() ReplayCache {
+	return &memoryReplayCache{keys: make(map[string]time.Time)}
+}
+
+func (cache *memoryReplayCache) Add(key string, ttl time.Duration) bool {
+	now := time.Now()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if now.After(cache.nextPurge) {
+		for k, expire := range cache.keys {
+			if now.After(expire) {
+				delete(cache.keys, k)
+			}
+		}
+		cache.nextPurge = now.Add(ttl)
+	}
+	if expire, ok := cache.keys[key]; ok && !now.After(expire) {
+		return false
+	}
+	cache.keys[key] = now.Add(ttl)
+	return true
+}
+
+// Authenticator is a middleware which verifies requests, Authenticate of middlewares in
+// ServerOption.Middlewares is called before the request is dispatched and parameters are bound,
+// so that unauthenticated requests learn nothing about actions and parameters
+type Authenticator interface {
+	core.Middleware
+	Authenticate(ctx *core.Context) error
+}
+
+type AuthOption struct {
+	SecretProvider SecretProvider
+	// ReplayCache rejects requests with the same signature, nil means replays are not detected
+	ReplayCache ReplayCache
+	// Algorithms are accepted algorithms and their hash functions, default is TC3-HMAC-SHA256
+	Algorithms map[string]func() hash.Hash
+	// Service is the expected service of credential scope, empty means any service
+	Service string
+	// TimestampHeader is the header of unix timestamp of the request, default is DefaultTimestampHeader
+	TimestampHeader string
+	// MaxSkew is the max difference between the timestamp of the request and server time, default is DefaultMaxSkew
+	MaxSkew time.Duration
+}
+
+type authMiddleware struct {
+	option AuthOption
+	now    func() time.Time
+}
+
+// NewAuthMiddleware create middleware which verifies TC3-HMAC-SHA256 style signature of requests,
+// and fill ctx.UserInfo with the account of SecretId only after verification succeeds.
+// It's an Authenticator, requests are verified before they are dispatched and parameters are bound
+//
+// The signature is sent in Authorization header like:
+//
+//	TC3-HMAC-SHA256 Credential=SecretId/2021-09-01/cvm/tc3_request, SignedHeaders=content-type;host, Signature=...
+func NewAuthMiddleware(option AuthOption) core.Middleware {
+	if option.SecretProvider == nil {
+		eslog.L().Panic("auth middleware requires SecretProvider")
+	}
+	if len(option.Algorithms) == 0 {
+		option.Algorithms = map[string]func() hash.Hash{TC3Algorithm: sha256.New}
+	}
+	if option.TimestampHeader == "" {
+		option.TimestampHeader = DefaultTimestampHeader
+	}
+	if option.MaxSkew <= 0 {
+		option.MaxSkew = DefaultMaxSkew
+	}
+	return &authMiddleware{option: option, now: time.Now}
+}
+
+// Authenticate verify signature of the request and fill ctx.UserInfo,
+// Server calls it before the request is dispatched and parameters are bound
+func (middleware *authMiddleware) Authenticate(ctx *core.Context) error {
+	secretId, secret, err := middleware.authenticate(ctx)
+	if err != nil {
+		eslog.C(ctx).Warn("authenticate failed", eslog.Err(err))
+		return err
+	}
+
+	ctx.Set(AuthSecretIdKey, secretId)
+	ctx.UserInfo.AppId = secret.AppId
+	ctx.UserInfo.Uin = secret.Uin
+	ctx.UserInfo.SubAccountUin = secret.SubAccountUin
+	return nil
+}
+
+func (middleware *authMiddleware) Run(ctx *core.Context) error {
+	// requests are authenticated by Server already, unless the middleware is used by other chains
+	if _, ok := ctx.Get(AuthSecretIdKey); !ok {
+		if err := middleware.Authenticate(ctx); err != nil {
+			ctx.Error = err
+			return err
+		}
+	}
+	return ctx.Next()
+}
+
+func (middleware *authMiddleware) authenticate(ctx *core.Context) (string, *Secret, error) {
+	r := ctx.Request
+	value := r.Header.Get(AuthorizationHeader)
+	if value == "" {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureMissingAuthorizationCode,
+			map[string]interface{}{"header": AuthorizationHeader})
+	}
+	authorization, err := parseAuthorization(value)
+	if err != nil {
+		return "", nil, err
+	}
+	newHash, ok := middleware.option.Algorithms[authorization.algorithm]
+	if !ok {
+		return "", nil, invalidAuthorization("algorithm `" + authorization.algorithm + "` is not supported")
+	}
+	if middleware.option.Service != "" && authorization.service != middleware.option.Service {
+		return "", nil, invalidAuthorization("service `" + authorization.service + "` is not expected")
+	}
+
+	timestampValue := r.Header.Get(middleware.option.TimestampHeader)
+	if timestampValue == "" {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureMissingAuthorizationCode,
+			map[string]interface{}{"header": middleware.option.TimestampHeader})
+	}
+	unix, err := strconv.ParseInt(timestampValue, 10, 64)
+	if err != nil {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureInvalidAuthorizationCode,
+			map[string]interface{}{"header": middleware.option.TimestampHeader, "reason": "not a unix timestamp"})
+	}
+	timestamp := time.Unix(unix, 0)
+	if skew := middleware.now().Sub(timestamp); skew > middleware.option.MaxSkew || -skew > middleware.option.MaxSkew {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureSignatureExpireCode,
+			map[string]interface{}{"timestamp": timestampValue, "maxSkew": middleware.option.MaxSkew})
+	}
+	if authorization.date != timestamp.UTC().Format(tc3DateLayout) {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureSignatureFailureCode, nil)
+	}
+
+	secret, err := middleware.option.SecretProvider.GetSecret(ctx, authorization.secretId)
+	if err != nil {
+		eslog.C(ctx).Error("get secret failed", eslog.Field("SecretId", authorization.secretId), eslog.Err(err))
+		return "", nil, eserrors.InternalError()
+	}
+	if secret == nil {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureSecretIdNotFoundCode,
+			map[string]interface{}{"secretId": authorization.secretId})
+	}
+
+	signature := tc3Signature(newHash, authorization, r, ctx.Body, timestampValue, secret.SecretKey)
+	if !hmac.Equal([]byte(signature), []byte(authorization.signature)) {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureSignatureFailureCode, nil)
+	}
+
+	// signature is valid until the timestamp is skewed, replays are detected in the period
+	if cache := middleware.option.ReplayCache; cache != nil &&
+		!cache.Add(authorization.secretId+"/"+signature, 2*middleware.option.MaxSkew) {
+		return "", nil, eserrors.AuthFailureEx(eserrors.AuthFailureSignatureReplayedCode, nil)
+	}
+	return authorization.secretId, secret, nil
+}
+
+const tc3DateLayout = "2006-01-02"
+
+// tc3Authorization is the parsed Authorization header
+type tc3Authorization struct {
+	algorithm     string
+	secretId      string
+	date          string
+	service       string
+	signedHeaders []string
+	signature     string
+}
+
+func (authorization *tc3Authorization) credentialScope() string {
+	return authorization.date + "/" + authorization.service + "/" + tc3Terminator
+}
+
+func invalidAuthorization(reason string) error {
+	return eserrors.AuthFailureEx(eserrors.AuthFailureInvalidAuthorizationCode,
+		map[string]interface{}{"header": AuthorizationHeader, "reason": reason})
+}
+
+// parseAuthorization parse `Algorithm Credential=..., SignedHeaders=..., Signature=...`
+func parseAuthorization(value string) (*tc3Authorization, error) {
+	index := strings.IndexByte(value, ' ')
+	if index < 0 {
+		return nil, invalidAuthorization("missing credential")
+	}
+	authorization := &tc3Authorization{algorithm: value[:index]}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Split(value[index+1:], ",") {
+		field = strings.TrimSpace(field)
+		eq := strings.IndexByte(field, '=')
+		if eq < 0 {
+			return nil, invalidAuthorization("field `" + field + "` is not a key-value pair")
+		}
+		fields[field[:eq]] = field[eq+1:]
+	}
+
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 4 || credential[0] == "" || credential[3] != tc3Terminator {
+		return nil, invalidAuthorization("Credential must be `SecretId/Date/Service/" + tc3Terminator + "`")
+	}
+	authorization.secretId, authorization.date, authorization.service =
+		credential[0], credential[1], credential[2]
+
+	if fields["SignedHeaders"] == "" {
+		return nil, invalidAuthorization("missing SignedHeaders")
+	}
+	authorization.signedHeaders = strings.Split(strings.ToLower(fields["SignedHeaders"]), ";")
+	if !sort.StringsAreSorted(authorization.signedHeaders) {
+		return nil, invalidAuthorization("SignedHeaders must be sorted")
+	}
+	hasHost := false
+	for _, header := range authorization.signedHeaders {
+		hasHost = hasHost || header == "host"
+	}
+	if !hasHost {
+		return nil, invalidAuthorization("header `host` must be signed")
+	}
+
+	if authorization.signature = fields["Signature"]; authorization.signature == "" {
+		return nil, invalidAuthorization("missing Signature")
+	}
+	return authorization, nil
+}
+
+// tc3Signature compute signature of r in TC3 style:
+//
+//	CanonicalRequest = Method\nURI\nQueryString\nCanonicalHeaders\nSignedHeaders\nHash(Body)
+//	StringToSign     = Algorithm\nTimestamp\nCredentialScope\nHash(CanonicalRequest)
+//	Signature        = HMAC(HMAC(HMAC(HMAC("TC3"+SecretKey, Date), Service), "tc3_request"), StringToSign)
+func tc3Signature(newHash func() hash.Hash, authorization *tc3Authorization, r *http.Request,
+	body []byte, timestamp, secretKey string) string {
+
+	uri := r.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	var canonicalHeaders strings.Builder
+	for _, header := range authorization.signedHeaders {
+		value := r.Header.Get(header)
+		if header == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(header + ":" + strings.ToLower(strings.TrimSpace(value)) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		uri,
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(authorization.signedHeaders, ";"),
+		hashHex(newHash, body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		authorization.algorithm,
+		timestamp,
+		authorization.credentialScope(),
+		hashHex(newHash, []byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSum(newHash, []byte(tc3KeyPrefix+secretKey), authorization.date)
+	key = hmacSum(newHash, key, authorization.service)
+	key = hmacSum(newHash, key, tc3Terminator)
+	return hex.EncodeToString(hmacSum(newHash, key, stringToSign))
+}
+
+func hashHex(newHash func() hash.Hash, data []byte) string {
+	h := newHash()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hmacSum(newHash func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,151 @@
+package framework
+
+import (
+	"crypto/sha256"
+	"github.com/SongOf/edge-storage-core/core"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signRequest sign r in the same way as clients
+func signRequest(r *http.Request, body, secretId, secretKey string, timestamp time.Time) {
+	authorization := &tc3Authorization{
+		algorithm:     TC3Algorithm,
+		secretId:      secretId,
+		date:          timestamp.UTC().Format(tc3DateLayout),
+		service:       "es",
+		signedHeaders: []string{"content-type", "host"},
+	}
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	signature := tc3Signature(sha256.New, authorization, r, []byte(body), unix, secretKey)
+	r.Header.Set(DefaultTimestampHeader, unix)
+	r.Header.Set(AuthorizationHeader, TC3Algorithm+" Credential="+secretId+"/"+authorization.credentialScope()+
+		", SignedHeaders=content-type;host, Signature="+signature)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	factory := testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					return &testResponse{Name: ctx.UserInfo.Uin}, nil
+				},
+			}
+		},
+	}
+	s := newTestServer(factory, ServerOption{Middlewares: []core.Middleware{NewAuthMiddleware(AuthOption{
+		SecretProvider: StaticSecretProvider{"AKIDtest": {SecretKey: "secret", AppId: 1, Uin: "100"}},
+		ReplayCache:    NewMemoryReplayCache(),
+	})}})
+
+	const body = `{"Action":"DescribeTest","Name":"a","Limit":1}`
+	newRequest := func(secretId, secretKey string, timestamp time.Time) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", MIMEJSON)
+		signRequest(r, body, secretId, secretKey, timestamp)
+		return r
+	}
+
+	signedAt := time.Now().Add(-time.Second)
+	tests := []struct {
+		name     string
+		request  func() *http.Request
+		wantCode string
+	}{
+		{name: "valid signature", request: func() *http.Request { return newRequest("AKIDtest", "secret", signedAt) }},
+		{
+			name:     "replayed",
+			request:  func() *http.Request { return newRequest("AKIDtest", "secret", signedAt) },
+			wantCode: "AuthFailure.SignatureReplayed",
+		},
+		{
+			name: "missing authorization",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			},
+			wantCode: "AuthFailure.MissingAuthorization",
+		},
+		{
+			name: "invalid parameters without authorization",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Action":"DescribeTest","Limit":"a"}`))
+			},
+			wantCode: "AuthFailure.MissingAuthorization",
+		},
+		{
+			name: "unknown action without authorization",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Action":"DeleteTest"}`))
+			},
+			wantCode: "AuthFailure.MissingAuthorization",
+		},
+		{
+			name:     "wrong secret key",
+			request:  func() *http.Request { return newRequest("AKIDtest", "guess", time.Now()) },
+			wantCode: "AuthFailure.SignatureFailure",
+		},
+		{
+			name:     "unknown secret id",
+			request:  func() *http.Request { return newRequest("AKIDunknown", "secret", time.Now()) },
+			wantCode: "AuthFailure.SecretIdNotFound",
+		},
+		{
+			name:     "clock skewed",
+			request:  func() *http.Request { return newRequest("AKIDtest", "secret", time.Now().Add(-time.Hour)) },
+			wantCode: "AuthFailure.SignatureExpire",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := serveRequest(t, s.Handler(), tt.request())
+			if tt.wantCode == "" {
+				if reply.Response.Error != nil {
+					t.Fatalf("unexpected error %+v", *reply.Response.Error)
+				}
+				if reply.Response.Name != "100" {
+					t.Errorf("UserInfo.Uin = %q, want 100", reply.Response.Name)
+				}
+				return
+			}
+			if reply.Response.Error == nil || reply.Response.Error.Code != tt.wantCode {
+				t.Errorf("unexpected reply %+v, want code %s", reply.Response, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			value: "TC3-HMAC-SHA256 Credential=AKID/2021-09-01/es/tc3_request, SignedHeaders=content-type;host, Signature=abc",
+		},
+		{name: "missing credential", value: "TC3-HMAC-SHA256", wantErr: true},
+		{
+			name:    "host not signed",
+			value:   "TC3-HMAC-SHA256 Credential=AKID/2021-09-01/es/tc3_request, SignedHeaders=content-type, Signature=abc",
+			wantErr: true,
+		},
+		{
+			name:    "bad scope",
+			value:   "TC3-HMAC-SHA256 Credential=AKID/2021-09-01/es, SignedHeaders=host, Signature=abc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAuthorization(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("parseAuthorization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	// anyResource is the resource of actions which declare no resources
+	anyResource = "*"
+)
+
+// condition keys of policy statement
+const (
+	ConditionKeyIp            = "qcs:ip"
+	ConditionKeyAction        = "qcs:action"
+	ConditionKeyVersion       = "qcs:version"
+	ConditionKeyUin           = "qcs:uin"
+	ConditionKeySubAccountUin = "qcs:sub_account_uin"
+	// ConditionKeyResourceTag is prefix of resource tag keys, like `qcs:resource_tag/env`
+	ConditionKeyResourceTag = "qcs:resource_tag/"
+)
+
+// Resource is a resource accessed by the request, like `qcs::cbs:ap-guangzhou:uin/100:volume/disk-1`
+type Resource struct {
+	Name string
+	Tags map[string]string
+}
+
+// Policy is a CAM style policy document:
+//
+//	{
+//	  "version": "2.0",
+//	  "statement": [{
+//	    "effect": "allow",
+//	    "action": ["cbs:Describe*"],
+//	    "resource": ["qcs::cbs:*:uin/100:volume/*"],
+//	    "condition": {"string_equal": {"qcs:resource_tag/env": ["prod"]}}
+//	  }]
+//	}
+//
+// A request is authorized if every resource is allowed by a statement and denied by none.
+type Policy struct {
+	Version   string       `json:"version"`
+	Statement []*Statement `json:"statement"`
+}
+
+type Statement struct {
+	Effect   string     `json:"effect"`
+	Action   stringList `json:"action"`
+	Resource stringList `json:"resource"`
+	// Condition is keyed by operator and then condition key, values of a key are ORed, others are ANDed
+	Condition map[string]map[string]stringList `json:"condition,omitempty"`
+}
+
+// stringList is a list of string, which can be a single string in json
+type stringList []string
+
+func (list *stringList) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*list = stringList{value}
+		return nil
+	}
+	values := make([]string, 0)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*list = values
+	return nil
+}
+
+// validate check effect and condition operators of policy
+func (policy *Policy) validate() error {
+	for i, statement := range policy.Statement {
+		if statement.Effect != EffectAllow && statement.Effect != EffectDeny {
+			return fmt.Errorf("statement %d: invalid effect `%s`", i, statement.Effect)
+		}
+		if len(statement.Action) == 0 {
+			return fmt.Errorf("statement %d: missing action", i)
+		}
+		if len(statement.Resource) == 0 {
+			return fmt.Errorf("statement %d: missing resource", i)
+		}
+		for operator := range statement.Condition {
+			if _, ok := conditionOperators[operator]; !ok {
+				return fmt.Errorf("statement %d: unknown condition operator `%s`", i, operator)
+			}
+		}
+	}
+	return nil
+}
+
+// Principal is the caller to be authorized
+type Principal struct {
+	AppId         int
+	Uin           string
+	SubAccountUin string
+}
+
+// PolicyStore provides policies attached to principal
+type PolicyStore interface {
+	GetPolicies(ctx context.Context, principal Principal) ([]*Policy, error)
+}
+
+// FilePolicyStore is a PolicyStore loaded from a json file, which maps uin to its policies:
+//
+//	{"100001": [policy...], "100002": [policy...]}
+//
+// Policies of sub account are looked up by SubAccountUin, and root account by Uin
+type FilePolicyStore struct {
+	path string
+
+	mu       sync.RWMutex
+	policies map[string][]*Policy
+}
+
+func NewFilePolicyStore(path string) (*FilePolicyStore, error) {
+	store := &FilePolicyStore{path: path}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Reload load policies from file again, policies are not changed if file is invalid
+func (store *FilePolicyStore) Reload() error {
+	data, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		return err
+	}
+	policies := make(map[string][]*Policy)
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return fmt.Errorf("parse policy file %s: %w", store.path, err)
+	}
+	for uin, list := range policies {
+		for _, policy := range list {
+			if err := policy.validate(); err != nil {
+				return fmt.Errorf("invalid policy of %s: %w", uin, err)
+			}
+		}
+	}
+
+	store.mu.Lock()
+	store.policies = policies
+	store.mu.Unlock()
+	return nil
+}
+
+func (store *FilePolicyStore) GetPolicies(_ context.Context, principal Principal) ([]*Policy, error) {
+	uin := principal.SubAccountUin
+	if uin == "" {
+		uin = principal.Uin
+	}
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.policies[uin], nil
+}
+
+type AuthzOption struct {
+	Store PolicyStore
+	// Service prefixes action in statements, like `cbs:DescribeVolumes`, it's ignored if ActionMeta.Permission is set
+	Service string
+	// IpHeader and TrustedHops identify IP of client for `qcs:ip` like RateLimitOption,
+	// remote address is used if IpHeader is empty
+	IpHeader    string
+	TrustedHops int
+}
+
+type authzMiddleware struct {
+	option AuthzOption
+}
+
+// NewAuthzMiddleware create middleware which authorizes the action and resources of request by policies of caller,
+// it should run after caller is identified, e.g. by NewAuthMiddleware.
+// Action in statements is ActionMeta.Permission, or ctx.Action prefixed by AuthzOption.Service,
+// and resources are declared by ResourceController.
+func NewAuthzMiddleware(option AuthzOption) core.Middleware {
+	if option.Store == nil {
+		eslog.L().Panic("authz middleware requires PolicyStore")
+	}
+	return &authzMiddleware{option: option}
+}
+
+func (middleware *authzMiddleware) Run(ctx *core.Context) error {
+	if err := middleware.authorize(ctx); err != nil {
+		eslog.C(ctx).Warn("authorize failed", eslog.Err(err))
+		ctx.Error = err
+		return err
+	}
+	return ctx.Next()
+}
+
+func (middleware *authzMiddleware) authorize(ctx *core.Context) error {
+	action := ctx.Action
+	if middleware.option.Service != "" {
+		action = middleware.option.Service + ":" + action
+	}
+	if value, ok := ctx.Get(ActionMetaKey); ok {
+		if meta := value.(ActionMeta); meta.Permission != "" {
+			action = meta.Permission
+		}
+	}
+
+	resources := []Resource{{Name: anyResource}}
+	if value, ok := ctx.Get(ControllerKey); ok {
+		if controller, ok := value.(ResourceController); ok {
+			declared, err := controller.GetResources(ctx)
+			if err != nil {
+				return err
+			}
+			if len(declared) > 0 {
+				resources = declared
+			}
+		}
+	}
+
+	principal := Principal{AppId: ctx.UserInfo.AppId, Uin: ctx.UserInfo.Uin, SubAccountUin: ctx.UserInfo.SubAccountUin}
+	policies, err := middleware.option.Store.GetPolicies(ctx, principal)
+	if err != nil {
+		eslog.C(ctx).Error("get policies failed", eslog.Field("Principal", principal), eslog.Err(err))
+		return eserrors.InternalError()
+	}
+
+	values := map[string]string{
+		ConditionKeyAction:        ctx.Action,
+		ConditionKeyVersion:       ctx.Version,
+		ConditionKeyUin:           ctx.UserInfo.Uin,
+		ConditionKeySubAccountUin: ctx.UserInfo.SubAccountUin,
+	}
+	if ctx.Request != nil {
+		values[ConditionKeyIp] = clientIp(ctx.Request, middleware.option.IpHeader, middleware.option.TrustedHops)
+	}
+	for _, resource := range resources {
+		if !isAllowed(policies, action, resource, values) {
+			return eserrors.UnauthorizedOperation(action, resource.Name)
+		}
+	}
+	return nil
+}
+
+// isAllowed report whether action on resource is allowed by any statement and denied by none
+func isAllowed(policies []*Policy, action string, resource Resource, values map[string]string) bool {
+	lookup := func(key string) (string, bool) {
+		if strings.HasPrefix(key, ConditionKeyResourceTag) {
+			value, ok := resource.Tags[strings.TrimPrefix(key, ConditionKeyResourceTag)]
+			return value, ok
+		}
+		value, ok := values[key]
+		return value, ok && value != ""
+	}
+
+	allowed := false
+	for _, policy := range policies {
+		for _, statement := range policy.Statement {
+			if !matchAny(statement.Action, action) || !matchAny(statement.Resource, resource.Name) ||
+				!matchCondition(statement.Condition, lookup) {
+				continue
+			}
+			if statement.Effect == EffectDeny {
+				return false
+			}
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch match value against pattern, in which `*` matches any characters and `?` matches one character
+func globMatch(pattern, value string) bool {
+	p, v := 0, 0
+	star, next := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, v
+			p++
+		case star >= 0:
+			// let the last `*` match one more character
+			p = star + 1
+			next++
+			v = next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// conditionOperator report whether value matches any of expected values,
+// negated operators are satisfied if the key is missing
+type conditionOperator struct {
+	match   func(expected, value string) bool
+	negated bool
+}
+
+var conditionOperators = map[string]conditionOperator{
+	"string_equal":     {match: func(expected, value string) bool { return expected == value }},
+	"string_not_equal": {match: func(expected, value string) bool { return expected == value }, negated: true},
+	"string_like":      {match: globMatch},
+	"string_not_like":  {match: globMatch, negated: true},
+	"ip_equal":         {match: ipMatch},
+	"ip_not_equal":     {match: ipMatch, negated: true},
+	"numeric_equal": {match: func(expected, value string) bool {
+		a, errA := strconv.ParseFloat(expected, 64)
+		b, errB := strconv.ParseFloat(value, 64)
+		return errA == nil && errB == nil && a == b
+	}},
+}
+
+// ipMatch match ip against ip or cidr
+func ipMatch(expected, value string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	if _, network, err := net.ParseCIDR(expected); err == nil {
+		return network.Contains(ip)
+	}
+	return ip.Equal(net.ParseIP(expected))
+}
+
+func matchCondition(condition map[string]map[string]stringList, lookup func(string) (string, bool)) bool {
+	for name, keys := range condition {
+		operator, ok := conditionOperators[name]
+		if !ok {
+			return false
+		}
+		for key, expected := range keys {
+			value, ok := lookup(key)
+			matched := false
+			for _, e := range expected {
+				if ok && operator.match(e, value) {
+					matched = true
+					break
+				}
+			}
+			if matched == operator.negated {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package framework
+
+import (
+	"github.com/SongOf/edge-storage-core/core"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicies = `{
+  "200": [{
+    "version": "2.0",
+    "statement": [
+      {
+        "effect": "allow",
+        "action": "es:Describe*",
+        "resource": "qcs::es::uin/100:bucket/*",
+        "condition": {"string_equal": {"qcs:resource_tag/env": ["dev", "test"]}}
+      },
+      {"effect": "deny", "action": "es:DescribeSecret", "resource": "*"},
+      {"effect": "allow", "action": "es:Ping", "resource": "*", "condition": {"ip_equal": {"qcs:ip": "192.0.2.0/24"}}}
+    ]
+  }]
+}`
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "", true},
+		{"es:Describe*", "es:DescribeTest", true},
+		{"es:Describe*", "es:CreateTest", false},
+		{"qcs::es:*:uin/100:bucket/*", "qcs::es:gz:uin/100:bucket/b1", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+type testResourceController struct {
+	testController
+}
+
+func (c *testResourceController) GetResources(ctx *core.Context) ([]Resource, error) {
+	return []Resource{{
+		Name: "qcs::es::uin/100:bucket/" + c.description.Name,
+		Tags: map[string]string{"env": c.description.Name},
+	}}, nil
+}
+
+type testPrincipalMiddleware struct{}
+
+func (testPrincipalMiddleware) Run(ctx *core.Context) error {
+	ctx.UserInfo.Uin, ctx.UserInfo.SubAccountUin = "100", "200"
+	return ctx.Next()
+}
+
+func TestAuthzMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	if err := ioutil.WriteFile(path, []byte(testPolicies), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFilePolicyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newController := func() Controller { return &testResourceController{} }
+	registry := NewRegistry()
+	registry.Register("DescribeTest", newController)
+	registry.Register("DescribeSecret", newController)
+	registry.Register("CreateTest", newController)
+	registry.Register("Ping", func() Controller { return &testController{} }, ActionMeta{Permission: "es:Ping"})
+	s := NewServer(NewRouter(registry), ServerOption{Middlewares: []core.Middleware{
+		testPrincipalMiddleware{},
+		NewAuthzMiddleware(AuthzOption{Store: store, Service: "es", IpHeader: "X-Forwarded-For"}),
+	}})
+
+	tests := []struct {
+		name     string
+		body     string
+		headers  []string
+		wantCode string
+	}{
+		{name: "allowed by tag", body: `{"Action":"DescribeTest","Name":"dev","Limit":1}`},
+		{
+			name:     "tag not matched",
+			body:     `{"Action":"DescribeTest","Name":"prod","Limit":1}`,
+			wantCode: "UnauthorizedOperation",
+		},
+		{
+			name:     "explicit deny",
+			body:     `{"Action":"DescribeSecret","Name":"dev","Limit":1}`,
+			wantCode: "UnauthorizedOperation",
+		},
+		{
+			name:     "action not allowed",
+			body:     `{"Action":"CreateTest","Name":"dev","Limit":1}`,
+			wantCode: "UnauthorizedOperation",
+		},
+		// requests of httptest are from 192.0.2.1
+		{name: "permission of action meta", body: `{"Action":"Ping","Name":"a","Limit":1}`},
+		{
+			name:    "ip of trusted proxy",
+			body:    `{"Action":"Ping","Name":"a","Limit":1}`,
+			headers: []string{"X-Forwarded-For", "198.51.100.1, 192.0.2.7"},
+		},
+		{
+			name:     "spoofed ip",
+			body:     `{"Action":"Ping","Name":"a","Limit":1}`,
+			headers:  []string{"X-Forwarded-For", "192.0.2.7, 198.51.100.1"},
+			wantCode: "UnauthorizedOperation",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := doRequest(t, s.Handler(), tt.body, tt.headers...)
+			if tt.wantCode == "" {
+				if reply.Response.Error != nil {
+					t.Fatalf("unexpected error %+v", *reply.Response.Error)
+				}
+				return
+			}
+			if reply.Response.Error == nil || reply.Response.Error.Code != tt.wantCode {
+				t.Errorf("unexpected reply %+v, want code %s", reply.Response, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFilePolicyStore_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	if err := ioutil.WriteFile(path, []byte(`{"200": [{"statement": [{"effect": "maybe", "action": "*"}]}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFilePolicyStore(path); err == nil {
+		t.Error("policy with invalid effect should be rejected")
+	}
+}
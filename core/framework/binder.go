@@ -0,0 +1,813 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	jsonTypeString  = "string"
+	jsonTypeNumber  = "number"
+	jsonTypeInteger = "integer"
+	jsonTypeBoolean = "boolean"
+	jsonTypeArray   = "array"
+	jsonTypeObject  = "object"
+	jsonTypeNull    = "null"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// bindField is a field of struct which can be bound from request
+type bindField struct {
+	name  string
+	index []int
+}
+
+// bindFields is fields of struct indexed by name
+type bindFields struct {
+	byName     map[string]*bindField
+	byFoldName map[string]*bindField
+}
+
+func (fields *bindFields) lookup(name string) *bindField {
+	if field, ok := fields.byName[name]; ok {
+		return field
+	}
+	// be compatible with case-insensitive matching of mapstructure and encoding/json
+	return fields.byFoldName[strings.ToLower(name)]
+}
+
+var bindFieldsCache sync.Map // map[reflect.Type]*bindFields
+
+// cachedBindFields return fields of struct type t, fields of embedded struct without
+// json name are promoted like encoding/json
+func cachedBindFields(t reflect.Type) *bindFields {
+	if cached, ok := bindFieldsCache.Load(t); ok {
+		return cached.(*bindFields)
+	}
+
+	fields := &bindFields{
+		byName:     make(map[string]*bindField),
+		byFoldName: make(map[string]*bindField),
+	}
+	collectBindFields(t, nil, fields)
+	cached, _ := bindFieldsCache.LoadOrStore(t, fields)
+	return cached.(*bindFields)
+}
+
+func collectBindFields(t reflect.Type, index []int, fields *bindFields) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectBindFields(ft, fieldIndex, fields)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		// fields of outer struct take precedence over embedded ones
+		if existing, ok := fields.byName[name]; ok && len(existing.index) <= len(fieldIndex) {
+			continue
+		}
+		field := &bindField{name: name, index: fieldIndex}
+		fields.byName[name] = field
+		fields.byFoldName[strings.ToLower(name)] = field
+	}
+}
+
+// binder bind params decoded from request body into a struct, and report errors with field path
+type binder struct {
+	// errs collects unknown parameters and type errors if not nil,
+	// otherwise binding stops at the first error
+	errs *eserrors.MultiError
+	// weak allows strings bound into number and boolean fields,
+	// and single value bound into slice
+	weak bool
+	// dec is the decoder of json body, values are decoded from it instead of params
+	dec *json.Decoder
+}
+
+func newBinder(description ControllerDescription, reportAll, weak bool) (*binder, reflect.Value, error) {
+	if description == nil {
+		return nil, reflect.Value{}, fmt.Errorf("description is nil")
+	}
+	value := reflect.ValueOf(description)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil, reflect.Value{}, fmt.Errorf("description must be a non-nil pointer")
+	}
+
+	b := &binder{weak: weak}
+	if reportAll {
+		b.errs = eserrors.NewMultiError()
+	}
+	return b, value.Elem(), nil
+}
+
+// decodeParams decode json object in body into description by tokens of json.Decoder, members of objects
+// are decoded in order of body, all unknown parameters and type errors are returned by
+// eserrors.MultiError if reportAll
+func decodeParams(body []byte, description ControllerDescription, reportAll, weak bool) error {
+	b, value, err := newBinder(description, reportAll, weak)
+	if err != nil {
+		return err
+	}
+	if err := b.decodeBody(body, value); err != nil {
+		return err
+	}
+	return b.errs.ErrorOrNil()
+}
+
+// decodeBody decode json object in body into struct v
+func (b *binder) decodeBody(body []byte, v reflect.Value) error {
+	b.dec = json.NewDecoder(bytes.NewReader(body))
+	b.dec.UseNumber()
+
+	// only one json object is allowed in body
+	if tok, err := b.dec.Token(); err != nil || tok != json.Delim('{') {
+		return jsonSyntaxError(err)
+	}
+	if err := b.decodeObject(nil, v); err != nil {
+		return err
+	}
+	if _, err := b.dec.Token(); err != io.EOF {
+		return jsonSyntaxError(err)
+	}
+	return nil
+}
+
+// report return err if binding stops at the first error,
+// otherwise err is collected and nil is returned to continue binding
+func (b *binder) report(path []string, err eserrors.EsError) error {
+	if b.errs == nil {
+		return err
+	}
+	b.errs.Append(formatPath(path), err)
+	return nil
+}
+
+// bind decode value into v
+func (b *binder) bind(path []string, value interface{}, v reflect.Value) error {
+	if value == nil {
+		// null leaves v unchanged, except pointer, map, slice and interface
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return b.bind(path, value, v.Elem())
+	}
+
+	if b.weak {
+		if s, ok := value.(string); ok {
+			value = weakValue(s, v.Kind())
+		}
+		if isScalar(value) && v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			// single value of slice
+			return b.bindSingle(path, value, v)
+		}
+	}
+
+	if v.CanAddr() {
+		pv := v.Addr()
+		if pv.Type().Implements(jsonUnmarshalerType) {
+			return b.bindUnmarshaler(path, value, pv)
+		}
+		if s, isString := value.(string); isString && pv.Type().Implements(textUnmarshalerType) {
+			if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return b.report(path, newTypeError(path, jsonTypeString, v.Type()))
+			}
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return b.typeError(path, value, v.Type())
+		}
+		v.Set(reflect.ValueOf(numberToFloat(value)))
+		return nil
+
+	case reflect.Struct:
+		if object, ok := value.(map[string]interface{}); ok {
+			return b.bindObject(path, object, v)
+		}
+
+	case reflect.Map:
+		if object, ok := value.(map[string]interface{}); ok && v.Type().Key().Kind() == reflect.String {
+			return b.bindMap(path, object, v)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if array, ok := value.([]interface{}); ok {
+			return b.bindArray(path, array, v)
+		}
+
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			v.SetString(s)
+			return nil
+		}
+
+	case reflect.Bool:
+		if boolean, ok := value.(bool); ok {
+			v.SetBool(boolean)
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number, ok := value.(json.Number); ok {
+			n, ok := parseInt(number)
+			if !ok || v.OverflowInt(n) {
+				return b.report(path, newTypeError(path, jsonTypeNumber, v.Type()))
+			}
+			v.SetInt(n)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number, ok := value.(json.Number); ok {
+			n, ok := parseUint(number)
+			if !ok || v.OverflowUint(n) {
+				return b.report(path, newTypeError(path, jsonTypeNumber, v.Type()))
+			}
+			v.SetUint(n)
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if number, ok := value.(json.Number); ok {
+			n, err := strconv.ParseFloat(string(number), v.Type().Bits())
+			if err != nil || v.OverflowFloat(n) {
+				return b.report(path, newTypeError(path, jsonTypeNumber, v.Type()))
+			}
+			v.SetFloat(n)
+			return nil
+		}
+	}
+
+	return b.typeError(path, value, v.Type())
+}
+
+// bindObject bind members of object into struct v
+func (b *binder) bindObject(path []string, object map[string]interface{}, v reflect.Value) error {
+	fields := cachedBindFields(v.Type())
+	for _, key := range sortedKeys(object) {
+		fieldPath := append(path, key)
+		field := fields.lookup(key)
+		if field == nil {
+			if ignoredParam(path, key) {
+				continue
+			}
+			if err := b.report(fieldPath, eserrors.UnknownParameter(formatPath(fieldPath))); err != nil {
+				return err
+			}
+			continue
+		}
+		fv, err := fieldByIndex(v, field.index)
+		if err != nil {
+			return err
+		}
+		if err := b.bind(fieldPath, object[key], fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ignoredParam report whether undeclared key of object at path is skipped instead of reported as unknown,
+// ClientToken of request is read from params by NewIdempotencyMiddleware
+func ignoredParam(path []string, key string) bool {
+	return len(path) == 0 && key == ParamClientToken
+}
+
+// bindMap bind members of object into map v
+func (b *binder) bindMap(path []string, object map[string]interface{}, v reflect.Value) error {
+	mapType := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(mapType))
+	}
+	for _, key := range sortedKeys(object) {
+		elem := reflect.New(mapType.Elem()).Elem()
+		if err := b.bind(append(path, key), object[key], elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key).Convert(mapType.Key()), elem)
+	}
+	return nil
+}
+
+// bindArray bind elements of array into slice or array v
+func (b *binder) bindArray(path []string, array []interface{}, v reflect.Value) error {
+	n := len(array)
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	} else if n > v.Len() {
+		// report once, and skip all extra elements
+		if err := b.report(path, newTypeError(path, jsonTypeArray, v.Type())); err != nil {
+			return err
+		}
+		n = v.Len()
+	}
+	for i := 0; i < n; i++ {
+		if err := b.bind(append(path, strconv.Itoa(i)), array[i], v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindSingle bind a single value into slice v as its only element
+func (b *binder) bindSingle(path []string, value interface{}, v reflect.Value) error {
+	elem := reflect.New(v.Type().Elem()).Elem()
+	if err := b.bind(append(path, "0"), value, elem); err != nil {
+		return err
+	}
+	v.Set(reflect.Append(reflect.MakeSlice(v.Type(), 0, 1), elem))
+	return nil
+}
+
+// decode decode the next value of dec into v
+func (b *binder) decode(path []string, v reflect.Value) error {
+	if decodedAsWhole(v.Type()) {
+		return b.decodeWhole(path, v)
+	}
+	tok, err := b.dec.Token()
+	if err != nil {
+		return jsonSyntaxError(err)
+	}
+	return b.decodeToken(path, tok, v)
+}
+
+// decodeToken decode value starting with tok into v
+func (b *binder) decodeToken(path []string, tok json.Token, v reflect.Value) error {
+	if tok != nil && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return b.decodeToken(path, tok, v.Elem())
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		switch {
+		case v.Kind() == reflect.Struct:
+			return b.decodeObject(path, v)
+		case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			return b.decodeMap(path, v)
+		}
+		if err := b.report(path, newTypeError(path, jsonTypeObject, v.Type())); err != nil {
+			return err
+		}
+		return b.skip(1)
+	case json.Delim('['):
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			return b.decodeArray(path, v)
+		}
+		if err := b.report(path, newTypeError(path, jsonTypeArray, v.Type())); err != nil {
+			return err
+		}
+		return b.skip(1)
+	}
+	// scalars are bound in the same way as params
+	return b.bind(path, tok, v)
+}
+
+// decodeObject decode members of object into struct v, the opening `{` has been read
+func (b *binder) decodeObject(path []string, v reflect.Value) error {
+	fields := cachedBindFields(v.Type())
+	for b.dec.More() {
+		tok, err := b.dec.Token()
+		if err != nil {
+			return jsonSyntaxError(err)
+		}
+		key, _ := tok.(string)
+		fieldPath := append(path, key)
+		field := fields.lookup(key)
+		if field == nil {
+			if !ignoredParam(path, key) {
+				if err := b.report(fieldPath, eserrors.UnknownParameter(formatPath(fieldPath))); err != nil {
+					return err
+				}
+			}
+			if err := b.skip(0); err != nil {
+				return err
+			}
+			continue
+		}
+		fv, err := fieldByIndex(v, field.index)
+		if err != nil {
+			return err
+		}
+		if err := b.decode(fieldPath, fv); err != nil {
+			return err
+		}
+	}
+	return b.closing()
+}
+
+// decodeMap decode members of object into map v, the opening `{` has been read
+func (b *binder) decodeMap(path []string, v reflect.Value) error {
+	mapType := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(mapType))
+	}
+	for b.dec.More() {
+		tok, err := b.dec.Token()
+		if err != nil {
+			return jsonSyntaxError(err)
+		}
+		key, _ := tok.(string)
+		elem := reflect.New(mapType.Elem()).Elem()
+		if err := b.decode(append(path, key), elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(key).Convert(mapType.Key()), elem)
+	}
+	return b.closing()
+}
+
+// decodeArray decode elements of array into slice or array v, the opening `[` has been read
+func (b *binder) decodeArray(path []string, v reflect.Value) error {
+	slice := v
+	if v.Kind() == reflect.Slice {
+		slice = reflect.MakeSlice(v.Type(), 0, 0)
+	}
+	for i := 0; b.dec.More(); i++ {
+		if v.Kind() == reflect.Array && i >= v.Len() {
+			// report once, and skip all extra elements
+			if i == v.Len() {
+				if err := b.report(path, newTypeError(path, jsonTypeArray, v.Type())); err != nil {
+					return err
+				}
+			}
+			if err := b.skip(0); err != nil {
+				return err
+			}
+			continue
+		}
+		elemPath := append(path, strconv.Itoa(i))
+		if v.Kind() == reflect.Array {
+			if err := b.decode(elemPath, v.Index(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := b.decode(elemPath, elem); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(slice)
+	}
+	return b.closing()
+}
+
+// decodedAsWhole report whether values of t are decoded by encoding/json as a whole,
+// like json.Unmarshaler, interface{} and []byte in base64
+func decodedAsWhole(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// decodeWhole decode the next value of dec into v by encoding/json
+func (b *binder) decodeWhole(path []string, v reflect.Value) error {
+	var raw json.RawMessage
+	if err := b.dec.Decode(&raw); err != nil {
+		return jsonSyntaxError(err)
+	}
+	if v.Kind() == reflect.Interface {
+		// numbers are float64 as encoding/json without UseNumber
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return jsonSyntaxError(err)
+		}
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+	if err := json.Unmarshal(raw, v.Addr().Interface()); err != nil {
+		return b.report(path, newTypeError(path, rawType(raw), v.Type()))
+	}
+	return nil
+}
+
+// skip skip the next value of dec, or the rest of an array or object whose opening delim has been read
+func (b *binder) skip(depth int) error {
+	for {
+		tok, err := b.dec.Token()
+		if err != nil {
+			return jsonSyntaxError(err)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// closing read the closing delim of array or object
+func (b *binder) closing() error {
+	if _, err := b.dec.Token(); err != nil {
+		return jsonSyntaxError(err)
+	}
+	return nil
+}
+
+// rawType return json type of raw value
+func rawType(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return jsonTypeNull
+	}
+	switch raw[0] {
+	case '{':
+		return jsonTypeObject
+	case '[':
+		return jsonTypeArray
+	case '"':
+		return jsonTypeString
+	case 't', 'f':
+		return jsonTypeBoolean
+	case 'n':
+		return jsonTypeNull
+	default:
+		return jsonTypeNumber
+	}
+}
+
+// jsonSyntaxError return InvalidParameter.Syntax of malformed json, err is nil if json isn't an object
+func jsonSyntaxError(err error) error {
+	switch err.(type) {
+	case nil, *json.SyntaxError, *json.UnmarshalTypeError:
+		return eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil)
+	}
+	return fmt.Errorf("unmarshal json error:%v", err)
+}
+
+// weakValue convert string s to value of kind, s is kept if it can't be converted
+func weakValue(s string, kind reflect.Kind) interface{} {
+	switch kind {
+	case reflect.Bool:
+		if boolean, err := strconv.ParseBool(s); err == nil {
+			return boolean
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	}
+	return s
+}
+
+// parseInt parse number as int64, integral floats like `10.0` are accepted as mapstructure did
+func parseInt(number json.Number) (int64, bool) {
+	if n, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(string(number), 64)
+	if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// parseUint parse number as uint64, integral floats like `10.0` are accepted as mapstructure did
+func parseUint(number json.Number) (uint64, bool) {
+	if n, err := strconv.ParseUint(string(number), 10, 64); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(string(number), 64)
+	if err != nil || f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// bindUnmarshaler encode value to json, and call json.Unmarshaler of pv
+func (b *binder) bindUnmarshaler(path []string, value interface{}, pv reflect.Value) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := pv.Interface().(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+		return b.report(path, newTypeError(path, valueType(value), pv.Elem().Type()))
+	}
+	return nil
+}
+
+// numberToFloat copy value with json.Number converted to float64, same as encoding/json without UseNumber
+func numberToFloat(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i := range v {
+			array[i] = numberToFloat(v[i])
+		}
+		return array
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key := range v {
+			object[key] = numberToFloat(v[key])
+		}
+		return object
+	}
+	return value
+}
+
+// typeError report type error of value
+func (b *binder) typeError(path []string, value interface{}, t reflect.Type) error {
+	return b.report(path, newTypeError(path, valueType(value), t))
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return false
+	}
+	return true
+}
+
+// fieldByIndex return field of v by index, nil embedded pointers are allocated
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, errors.New("can not set embedded pointer to unexported struct")
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func newTypeError(path []string, actualType string, expectType reflect.Type) eserrors.EsError {
+	return eserrors.InvalidParameterValueEx(
+		eserrors.InvalidParameterValueTypeCode,
+		map[string]interface{}{
+			"parameter":  formatPath(path),
+			"actualType": actualType,
+			"expectType": expectJSONType(expectType),
+		},
+	)
+}
+
+// formatPath format path like `Filters.0.Name`
+func formatPath(path []string) string {
+	return strings.Join(path, ".")
+}
+
+func valueType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return jsonTypeObject
+	case []interface{}:
+		return jsonTypeArray
+	case string:
+		return jsonTypeString
+	case json.Number, float64:
+		return jsonTypeNumber
+	case bool:
+		return jsonTypeBoolean
+	default:
+		return jsonTypeNull
+	}
+}
+
+func expectJSONType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return jsonTypeString
+	case reflect.Bool:
+		return jsonTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonTypeInteger
+	case reflect.Float32, reflect.Float64:
+		return jsonTypeNumber
+	case reflect.Slice, reflect.Array:
+		return jsonTypeArray
+	default:
+		return jsonTypeObject
+	}
+}
+
+// Bind decode request body into description, unknown fields are reported by UnknownParameter,
+// and type errors are reported by InvalidParameterValue.Type with field path like `Filters.0.Name`
+func (parser *Parser) Bind(ctx context.Context, body []byte, description ControllerDescription) error {
+	return decodeParams(body, description, false, false)
+}
+
+// BindAll is the same as Bind, except that it reports all unknown parameters and
+// type errors by eserrors.MultiError instead of stopping at the first one
+func (parser *Parser) BindAll(ctx context.Context, body []byte, description ControllerDescription) error {
+	return decodeParams(body, description, true, false)
+}
+
+// BindRequest bind request decoded by DecodeRequest into description, json body is decoded into description
+// directly, and params of other content types are bound. Query params absent from body are bound before body.
+// All unknown parameters and type errors are reported if reportAll
+func (parser *Parser) BindRequest(ctx context.Context, request *DecodedRequest,
+	description ControllerDescription, reportAll bool) error {
+
+	b, value, err := newBinder(description, reportAll, true)
+	if err != nil {
+		return err
+	}
+	if err := b.bindObject(nil, request.query, value); err != nil {
+		return err
+	}
+
+	b.weak = request.WeaklyTyped
+	if request.Body != nil {
+		err = b.decodeBody(request.Body, value)
+	} else {
+		err = b.bindObject(nil, request.values, value)
+	}
+	if err != nil {
+		return err
+	}
+	return b.errs.ErrorOrNil()
+}
@@ -0,0 +1,186 @@
+package framework
+
+import (
+	"context"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testFilter struct {
+	Name   string
+	Values []string
+}
+
+type testBindDescription struct {
+	BaseDescription
+	InstanceIds []string
+	Filters     []*testFilter
+	Limit       *int
+	Offset      uint
+	Ratio       float64
+	DryRun      bool
+	Tags        map[string]string
+	Extra       interface{}
+	Since       time.Time
+	Renamed     string `json:"NewName"`
+	Ignored     string `json:"-"`
+}
+
+func TestParser_Bind(t *testing.T) {
+	parser, _ := NewParser()
+	limit := 20
+
+	tests := []struct {
+		name     string
+		body     string
+		want     *testBindDescription
+		wantCode string
+		wantData interface{}
+	}{
+		{
+			name: "bind all fields",
+			body: `{"Action":"DescribeTest","InstanceIds":["ins-1"],"Filters":[{"Name":"zone","Values":["a"]}],` +
+				`"Limit":20,"Offset":1,"Ratio":0.5,"DryRun":true,"Tags":{"k":"v"},"Extra":{"n":1},` +
+				`"Since":"2021-09-01T00:00:00Z","newname":"x"}`,
+			want: &testBindDescription{
+				BaseDescription: BaseDescription{Action: "DescribeTest"},
+				InstanceIds:     []string{"ins-1"},
+				Filters:         []*testFilter{{Name: "zone", Values: []string{"a"}}},
+				Limit:           &limit,
+				Offset:          1,
+				Ratio:           0.5,
+				DryRun:          true,
+				Tags:            map[string]string{"k": "v"},
+				Extra:           map[string]interface{}{"n": float64(1)},
+				Since:           time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC),
+				Renamed:         "x",
+			},
+		},
+		{
+			name:     "unknown nested field",
+			body:     `{"Filters":[{"Name":"zone"},{"Name":"zone","Value":"a"}]}`,
+			wantCode: "UnknownParameter",
+			wantData: struct{ ParameterName string }{ParameterName: "Filters.1.Value"},
+		},
+		{
+			name:     "ignored field is unknown",
+			body:     `{"Ignored":"x"}`,
+			wantCode: "UnknownParameter",
+			wantData: struct{ ParameterName string }{ParameterName: "Ignored"},
+		},
+		{
+			name: "undeclared ClientToken",
+			body: `{"ClientToken":"token-1","Offset":1}`,
+			want: &testBindDescription{Offset: 1},
+		},
+		{
+			name:     "nested ClientToken is unknown",
+			body:     `{"Filters":[{"ClientToken":"token-1"}]}`,
+			wantCode: "UnknownParameter",
+			wantData: struct{ ParameterName string }{ParameterName: "Filters.0.ClientToken"},
+		},
+		{
+			name:     "nested type error",
+			body:     `{"Filters":[{"Name":"zone","Values":"a"}]}`,
+			wantCode: "InvalidParameterValue.Type",
+			wantData: map[string]interface{}{"parameter": "Filters.0.Values", "actualType": "string", "expectType": "array"},
+		},
+		{
+			name:     "integer type error",
+			body:     `{"Offset":-1}`,
+			wantCode: "InvalidParameterValue.Type",
+			wantData: map[string]interface{}{"parameter": "Offset", "actualType": "number", "expectType": "integer"},
+		},
+		{
+			name: "integral float of integer",
+			body: `{"Limit":2e1,"Offset":10.0}`,
+			want: &testBindDescription{Limit: &limit, Offset: 10},
+		},
+		{
+			name:     "fractional float of integer",
+			body:     `{"Offset":1.5}`,
+			wantCode: "InvalidParameterValue.Type",
+			wantData: map[string]interface{}{"parameter": "Offset", "actualType": "number", "expectType": "integer"},
+		},
+		{
+			name:     "type error of object",
+			body:     `{"Filters":{"Name":"zone"}}`,
+			wantCode: "InvalidParameterValue.Type",
+			wantData: map[string]interface{}{"parameter": "Filters", "actualType": "object", "expectType": "array"},
+		},
+		{
+			name:     "type error of unmarshaler",
+			body:     `{"Since":1}`,
+			wantCode: "InvalidParameterValue.Type",
+			wantData: map[string]interface{}{"parameter": "Since", "actualType": "number", "expectType": "object"},
+		},
+		{
+			name:     "trailing data",
+			body:     `{"Offset":1}{}`,
+			wantCode: "InvalidParameter.Syntax",
+		},
+		{
+			name:     "syntax error",
+			body:     `{"Offset":1`,
+			wantCode: "InvalidParameter.Syntax",
+		},
+		{
+			name:     "not an object",
+			body:     `[1]`,
+			wantCode: "InvalidParameter.Syntax",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description := &testBindDescription{}
+			err := parser.Bind(context.Background(), []byte(tt.body), description)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("Bind() error = %v", err)
+				}
+				if !reflect.DeepEqual(description, tt.want) {
+					t.Errorf("Bind() = %+v, want %+v", description, tt.want)
+				}
+				return
+			}
+
+			esErr, ok := err.(eserrors.EsError)
+			if !ok {
+				t.Fatalf("Bind() error = %v, want EsError", err)
+			}
+			if code, _ := esErr.Format(); code != tt.wantCode {
+				t.Errorf("Bind() code = %s, want %s", code, tt.wantCode)
+			}
+			if tt.wantData != nil && !reflect.DeepEqual(esErr.GetData(), tt.wantData) {
+				t.Errorf("Bind() data = %+v, want %+v", esErr.GetData(), tt.wantData)
+			}
+		})
+	}
+}
+
+func TestParser_BindAll(t *testing.T) {
+	parser, _ := NewParser()
+	description := &testBindDescription{}
+	body := `{"Filters":[{"Name":1,"Values":["a"]},{"Unknown":{"a":[1,{}]}}],"Offset":"a","Tags":{"k":[]},"Ratio":0.5}`
+
+	err := parser.BindAll(context.Background(), []byte(body), description)
+	multiErr, ok := err.(*eserrors.MultiError)
+	if !ok {
+		t.Fatalf("BindAll() error = %v, want MultiError", err)
+	}
+	fields := make([]string, 0)
+	for _, fieldErr := range multiErr.Errors() {
+		fields = append(fields, fieldErr.Field)
+	}
+	// values after errors are still decoded
+	want := []string{"Filters.0.Name", "Filters.1.Unknown", "Offset", "Tags.k"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("BindAll() fields = %v, want %v", fields, want)
+	}
+	if description.Ratio != 0.5 || description.Filters[0].Values[0] != "a" {
+		t.Errorf("BindAll() = %+v", description)
+	}
+}
@@ -59,7 +59,7 @@ func (chain FunctionChain) Run(ctx *core.Context) error {
 	for index, unit := range chain {
 		// forward
 		if unit.ForwardFunc != nil {
-			err := runUnitFunc(ctx, unit.ForwardFunc)
+			err := runUnitFunc(ctx, "Unit", unit.ForwardFunc)
 			if err != nil {
 				failedIndex, failedError = index, err
 				eslog.C(ctx).Error("Run forward function failed.",
@@ -76,7 +76,7 @@ func (chain FunctionChain) Run(ctx *core.Context) error {
 			unit := chain[i]
 			if unit.RollbackFunc != nil {
 				// ignore rollback error
-				rollbackErr := runUnitFunc(ctx, unit.RollbackFunc)
+				rollbackErr := runUnitFunc(ctx, "Rollback", unit.RollbackFunc)
 
 				if rollbackErr != nil {
 					eslog.C(ctx).Error("Run rollback function failed.",
@@ -96,7 +96,10 @@ func (chain FunctionChain) Run(ctx *core.Context) error {
 	return nil
 }
 
-func runUnitFunc(ctx *core.Context, unitFunc Function) (err error) {
+// runUnitFunc run unitFunc in a span named by kind and name of the function
+func runUnitFunc(ctx *core.Context, kind string, unitFunc Function) (err error) {
+	end := ctx.StartSpan(kind + " " + GetFunctionName(unitFunc))
+	defer func() { end(err) }()
 	defer recovery.Recover(ctx, func() {
 		eslog.C(ctx).Warn("Unit Function raise panic!")
 		err = eserrors.InternalError()
@@ -1,48 +1,235 @@
 package framework
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"context"
+	"errors"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const (
+	DefaultMetricNamespace = "escore"
+	metricSubsystem        = "server"
+
+	// CodeSuccess is the code label of requests succeeded
+	CodeSuccess = "Success"
+	// CodeNoReply is the code label of requests canceled before reply
+	CodeNoReply = "NoReply"
+
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+var (
+	// DefaultLatencyBuckets are buckets of latency in seconds, from 5ms to 10s
+	DefaultLatencyBuckets = prometheus.DefBuckets
+	// DefaultSizeBuckets are buckets of body size in bytes, from 128B to 2MB
+	DefaultSizeBuckets = prometheus.ExponentialBuckets(128, 4, 8)
+)
+
+type CollectorOption struct {
+	// Namespace is the prefix of metric names, default is DefaultMetricNamespace
+	Namespace string
+	// ConstLabels are labels of all metrics of server, like region and node
+	ConstLabels prometheus.Labels
+	// LatencyBuckets are buckets of request latency in seconds, default is DefaultLatencyBuckets
+	LatencyBuckets []float64
+	// SizeBuckets are buckets of request and response size in bytes, default is DefaultSizeBuckets
+	SizeBuckets []float64
+}
 
 type ServerCollector struct {
-	ServerPanicCounter               prometheus.Counter
-	ControllerErrorCounterVector     *prometheus.CounterVec
+	ServerPanicCounter prometheus.Counter
+	// requests are labeled by action and version after dispatched, both are empty for requests of unknown action
+	RequestCounterVector          *prometheus.CounterVec
+	RequestLatencyHistogramVector *prometheus.HistogramVec
+	RequestSizeHistogramVector    *prometheus.HistogramVec
+	ResponseSizeHistogramVector   *prometheus.HistogramVec
+	// RequestsInFlightGauge is requests waiting for reply
+	RequestsInFlightGauge    prometheus.Gauge
+	RateLimitedCounterVector *prometheus.CounterVec
+	// concurrency limiters are labeled by `global` or action
+	InflightGaugeVector         *prometheus.GaugeVec
+	QueuedGaugeVector           *prometheus.GaugeVec
+	ConcurrencyLimitGaugeVector *prometheus.GaugeVec
+	ShedCounterVector           *prometheus.CounterVec
+
+	// Deprecated: use RequestCounterVector, which is labeled by error code.
+	// It's still exported as `server_error_total` of controller errors labeled by action.
+	ControllerErrorCounterVector *prometheus.CounterVec
+	// Deprecated: use RequestLatencyHistogramVector, which is labeled by version and outcome.
+	// It's still exported as `server_controller_latency_seconds` of controller latency labeled by action,
+	// since `server_latency_seconds` is taken by RequestLatencyHistogramVector.
 	ControllerLatencyHistogramVector *prometheus.HistogramVec
 }
 
+// NewCollector create ServerCollector with default CollectorOption
 func NewCollector() *ServerCollector {
-	panicCounter := prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Name: "escore_server_panic_total",
-			Help: "es-core server panic total count",
-		})
-	errorCounterVec := prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "escore_server_error_total",
-			Help: "es-core server controller error count.",
-		},
+	return NewCollectorEx(CollectorOption{})
+}
+
+// NewCollectorEx create ServerCollector with option
+func NewCollectorEx(option CollectorOption) *ServerCollector {
+	if option.Namespace == "" {
+		option.Namespace = DefaultMetricNamespace
+	}
+	if len(option.LatencyBuckets) == 0 {
+		option.LatencyBuckets = DefaultLatencyBuckets
+	}
+	if len(option.SizeBuckets) == 0 {
+		option.SizeBuckets = DefaultSizeBuckets
+	}
+	opts := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{
+			Namespace:   option.Namespace,
+			Subsystem:   metricSubsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: option.ConstLabels,
+		}
+	}
+	histogramOpts := func(name, help string, buckets []float64) prometheus.HistogramOpts {
+		return prometheus.HistogramOpts{
+			Namespace:   option.Namespace,
+			Subsystem:   metricSubsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: option.ConstLabels,
+			Buckets:     buckets,
+		}
+	}
+
+	panicCounter := prometheus.NewCounter(prometheus.CounterOpts(
+		opts("panic_total", "es-core server panic total count")))
+	requestCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts(
+		opts("requests_total", "es-core server requests count by error code.")),
+		[]string{"action", "version", "code"})
+	latencyHistogramVec := prometheus.NewHistogramVec(histogramOpts(
+		"latency_seconds", "es-core server request latency seconds.", option.LatencyBuckets),
+		[]string{"action", "version", "outcome"})
+	requestSizeHistogramVec := prometheus.NewHistogramVec(histogramOpts(
+		"request_size_bytes", "es-core server request body size bytes.", option.SizeBuckets),
+		[]string{"action", "version"})
+	responseSizeHistogramVec := prometheus.NewHistogramVec(histogramOpts(
+		"response_size_bytes", "es-core server response body size bytes.", option.SizeBuckets),
+		[]string{"action", "version"})
+	requestsInFlightGauge := prometheus.NewGauge(prometheus.GaugeOpts(
+		opts("requests_in_flight", "es-core server requests waiting for reply.")))
+	rateLimitedCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts(
+		opts("rate_limited_total", "es-core server requests rejected by rate limit count.")),
+		[]string{"action", "dimension"})
+	inflightGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts(
+		opts("inflight_requests", "es-core server requests in process.")),
+		[]string{"limiter"})
+	queuedGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts(
+		opts("queued_requests", "es-core server requests waiting for concurrency limit.")),
+		[]string{"limiter"})
+	limitGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts(
+		opts("concurrency_limit", "es-core server adaptive concurrency limit.")),
+		[]string{"limiter"})
+	shedCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts(
+		opts("shed_total", "es-core server requests rejected by concurrency limit count.")),
+		[]string{"limiter"})
+	errorCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts(
+		opts("error_total", "es-core server controller error count.")),
 		[]string{"action"})
-	latencyHistogramVec := prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "escore_server_latency_seconds",
-			Help:    "es-core server controller latency seconds.",
-			Buckets: []float64{0, 500, 1000, 5000},
-		},
+	controllerLatencyHistogramVec := prometheus.NewHistogramVec(histogramOpts(
+		"controller_latency_seconds", "es-core server controller latency seconds.", option.LatencyBuckets),
 		[]string{"action"})
 
 	return &ServerCollector{
-		ServerPanicCounter:               panicCounter,
+		ServerPanicCounter:            panicCounter,
+		RequestCounterVector:          requestCounterVec,
+		RequestLatencyHistogramVector: latencyHistogramVec,
+		RequestSizeHistogramVector:    requestSizeHistogramVec,
+		ResponseSizeHistogramVector:   responseSizeHistogramVec,
+		RequestsInFlightGauge:         requestsInFlightGauge,
+		RateLimitedCounterVector:      rateLimitedCounterVec,
+		InflightGaugeVector:           inflightGaugeVec,
+		QueuedGaugeVector:             queuedGaugeVec,
+		ConcurrencyLimitGaugeVector:   limitGaugeVec,
+		ShedCounterVector:             shedCounterVec,
+
 		ControllerErrorCounterVector:     errorCounterVec,
-		ControllerLatencyHistogramVector: latencyHistogramVec,
+		ControllerLatencyHistogramVector: controllerLatencyHistogramVec,
 	}
 }
 
 func (collector *ServerCollector) Collect(ch chan<- prometheus.Metric) {
 	collector.ServerPanicCounter.Collect(ch)
+	collector.RequestCounterVector.Collect(ch)
+	collector.RequestLatencyHistogramVector.Collect(ch)
+	collector.RequestSizeHistogramVector.Collect(ch)
+	collector.ResponseSizeHistogramVector.Collect(ch)
+	collector.RequestsInFlightGauge.Collect(ch)
+	collector.RateLimitedCounterVector.Collect(ch)
+	collector.InflightGaugeVector.Collect(ch)
+	collector.QueuedGaugeVector.Collect(ch)
+	collector.ConcurrencyLimitGaugeVector.Collect(ch)
+	collector.ShedCounterVector.Collect(ch)
 	collector.ControllerErrorCounterVector.Collect(ch)
 	collector.ControllerLatencyHistogramVector.Collect(ch)
 }
 
 func (collector *ServerCollector) Describe(ch chan<- *prometheus.Desc) {
 	collector.ServerPanicCounter.Describe(ch)
+	collector.RequestCounterVector.Describe(ch)
+	collector.RequestLatencyHistogramVector.Describe(ch)
+	collector.RequestSizeHistogramVector.Describe(ch)
+	collector.ResponseSizeHistogramVector.Describe(ch)
+	collector.RequestsInFlightGauge.Describe(ch)
+	collector.RateLimitedCounterVector.Describe(ch)
+	collector.InflightGaugeVector.Describe(ch)
+	collector.QueuedGaugeVector.Describe(ch)
+	collector.ConcurrencyLimitGaugeVector.Describe(ch)
+	collector.ShedCounterVector.Describe(ch)
 	collector.ControllerErrorCounterVector.Describe(ch)
 	collector.ControllerLatencyHistogramVector.Describe(ch)
 }
+
+// requestObserver observes metrics of a request when it's finished
+type requestObserver struct {
+	collector *ServerCollector
+	start     time.Time
+	// action and version are set after request is dispatched, so that unknown values are not exported
+	action      string
+	version     string
+	requestSize int64
+}
+
+func (collector *ServerCollector) observeRequest() *requestObserver {
+	collector.RequestsInFlightGauge.Inc()
+	return &requestObserver{collector: collector, start: time.Now()}
+}
+
+// finish observe the request with the reply of resp
+func (observer *requestObserver) finish(ctx *core.Context, resp *ServerResponse) {
+	collector := observer.collector
+	collector.RequestsInFlightGauge.Dec()
+
+	code, size := resp.sealer.replied()
+	if code == "" {
+		code = CodeNoReply
+	}
+	collector.RequestCounterVector.WithLabelValues(observer.action, observer.version, code).Inc()
+	collector.RequestLatencyHistogramVector.WithLabelValues(observer.action, observer.version,
+		requestOutcome(ctx, code)).Observe(time.Since(observer.start).Seconds())
+	collector.RequestSizeHistogramVector.WithLabelValues(observer.action, observer.version).
+		Observe(float64(observer.requestSize))
+	collector.ResponseSizeHistogramVector.WithLabelValues(observer.action, observer.version).
+		Observe(float64(size))
+}
+
+// requestOutcome return outcome of the finished request, timeout takes precedence over error
+func requestOutcome(ctx *core.Context, code string) string {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return OutcomeTimeout
+	case code != CodeSuccess:
+		return OutcomeError
+	default:
+		return OutcomeSuccess
+	}
+}
@@ -0,0 +1,90 @@
+package framework
+
+import (
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+func TestServerCollector(t *testing.T) {
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					if description.Name == "internal" {
+						return nil, eserrors.InternalError()
+					}
+					return &testResponse{Name: description.Name}, nil
+				},
+			}
+		},
+	}, ServerOption{CollectorOption: CollectorOption{
+		Namespace:   "test",
+		ConstLabels: prometheus.Labels{"region": "ap-guangzhou"},
+	}})
+
+	for _, body := range []string{
+		`{"Action":"DescribeTest","Name":"a","Limit":1}`,
+		`{"Action":"DescribeTest","Name":"internal","Limit":1}`,
+		`{"Action":"UnknownTest"}`,
+		`{"Name":"a"}`,
+	} {
+		doRequest(t, s.Handler(), body)
+	}
+
+	collector := s.Collector()
+	tests := []struct {
+		action, code string
+	}{
+		{"DescribeTest", CodeSuccess},
+		{"DescribeTest", "InternalError"},
+		// requests not dispatched are not labeled by action
+		{"", "InvalidAction"},
+		{"", "MissingParameter"},
+	}
+	for _, tt := range tests {
+		counter := collector.RequestCounterVector.WithLabelValues(tt.action, "", tt.code)
+		if got := testutil.ToFloat64(counter); got != 1 {
+			t.Errorf("requests of %q %s = %v, want 1", tt.action, tt.code, got)
+		}
+	}
+
+	// DescribeTest succeeded and failed, and requests not dispatched failed
+	if got := testutil.CollectAndCount(collector.RequestLatencyHistogramVector); got != 3 {
+		t.Errorf("latency series = %d, want 3", got)
+	}
+	if got := testutil.CollectAndCount(collector.ResponseSizeHistogramVector); got != 2 {
+		t.Errorf("response size series = %d, want 2", got)
+	}
+
+	expected := `
+# HELP test_server_requests_in_flight es-core server requests waiting for reply.
+# TYPE test_server_requests_in_flight gauge
+test_server_requests_in_flight{region="ap-guangzhou"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"test_server_requests_in_flight"); err != nil {
+		t.Error(err)
+	}
+
+	// series of deprecated ControllerErrorCounterVector is kept
+	expected = `
+# HELP test_server_error_total es-core server controller error count.
+# TYPE test_server_error_total counter
+test_server_error_total{action="DescribeTest",region="ap-guangzhou"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"test_server_error_total"); err != nil {
+		t.Error(err)
+	}
+	// deprecated ControllerLatencyHistogramVector is observed for dispatched requests
+	if got := testutil.CollectAndCount(collector, "test_server_controller_latency_seconds"); got != 1 {
+		t.Errorf("controller latency series = %d, want 1", got)
+	}
+	if err := prometheus.NewRegistry().Register(collector); err != nil {
+		t.Errorf("register collector: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// GlobalLimiter is the label of the limiter of all requests
+	GlobalLimiter = "global"
+
+	// aimdBackoff is the ratio to decrease limit when a request is dropped
+	aimdBackoff = 0.9
+)
+
+// ConcurrencyOption configures AIMD concurrency limiters of server.
+// A limit starts from its max value, it's decreased by 10% when a request is slower than TargetLatency or timeout,
+// and increased by 1 after a window of requests succeeds in time.
+// Requests over the limit wait in a queue, and are rejected with ResourceInsufficient if the queue is full.
+type ConcurrencyOption struct {
+	// MaxInflight is the max limit of all requests, it's checked before request body is read, zero means no limit
+	MaxInflight int
+	// MaxActionInflight is the max limit of each action, zero means no limit
+	MaxActionInflight int
+	// ActionInflight overrides MaxActionInflight for specific actions
+	ActionInflight map[string]int
+	// MinLimit is the lower bound of limits, default is 1
+	MinLimit int
+	// TargetLatency is the expected latency of requests, zero means only timeout decreases limits
+	TargetLatency time.Duration
+	// QueueSize is the max number of requests waiting for each limiter, zero means no queue
+	QueueSize int
+	// QueueTimeout is the max time to wait in queue, zero means waiting until request is canceled
+	QueueTimeout time.Duration
+}
+
+// aimdLimiter is a concurrency limiter with additive increase and multiplicative decrease
+type aimdLimiter struct {
+	name      string
+	option    *ConcurrencyOption
+	collector *ServerCollector
+
+	mu       sync.Mutex
+	limit    float64
+	max      float64
+	inflight int
+	// waiters are queued requests, a slot is handed over by closing the channel
+	waiters []chan struct{}
+}
+
+func newAIMDLimiter(name string, max int, option *ConcurrencyOption, collector *ServerCollector) *aimdLimiter {
+	limiter := &aimdLimiter{
+		name:      name,
+		option:    option,
+		collector: collector,
+		limit:     float64(max),
+		max:       float64(max),
+	}
+	limiter.report()
+	return limiter
+}
+
+// acquire take a slot, or wait in queue until a slot is released
+func (limiter *aimdLimiter) acquire(ctx context.Context) error {
+	limiter.mu.Lock()
+	if limiter.inflight < int(limiter.limit) {
+		limiter.inflight++
+		limiter.report()
+		limiter.mu.Unlock()
+		return nil
+	}
+	if len(limiter.waiters) >= limiter.option.QueueSize {
+		limiter.mu.Unlock()
+		return limiter.shed()
+	}
+	ready := make(chan struct{})
+	limiter.waiters = append(limiter.waiters, ready)
+	limiter.report()
+	limiter.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if limiter.option.QueueTimeout > 0 {
+		timer := time.NewTimer(limiter.option.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+		return nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	for i, waiter := range limiter.waiters {
+		if waiter == ready {
+			limiter.waiters = append(limiter.waiters[:i], limiter.waiters[i+1:]...)
+			limiter.report()
+			return limiter.shed()
+		}
+	}
+	// slot was handed over while giving up
+	return nil
+}
+
+// release return the slot, and adapt limit by whether the request is dropped
+func (limiter *aimdLimiter) release(dropped bool) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if dropped {
+		limiter.limit = math.Max(float64(limiter.minLimit()), limiter.limit*aimdBackoff)
+	} else if limiter.limit < limiter.max {
+		limiter.limit = math.Min(limiter.max, limiter.limit+1/limiter.limit)
+	}
+
+	// hand over every slot allowed by limit to queued requests, a slot may be freed by increased limit too
+	limiter.inflight--
+	for len(limiter.waiters) > 0 && limiter.inflight < int(limiter.limit) {
+		ready := limiter.waiters[0]
+		limiter.waiters = limiter.waiters[1:]
+		limiter.inflight++
+		close(ready)
+	}
+	limiter.report()
+}
+
+func (limiter *aimdLimiter) minLimit() int {
+	if limiter.option.MinLimit < 1 {
+		return 1
+	}
+	return limiter.option.MinLimit
+}
+
+func (limiter *aimdLimiter) shed() error {
+	if limiter.collector != nil {
+		limiter.collector.ShedCounterVector.WithLabelValues(limiter.name).Inc()
+	}
+	return eserrors.ResourceInsufficient()
+}
+
+// report update gauges, it must be called with mu held
+func (limiter *aimdLimiter) report() {
+	if limiter.collector == nil {
+		return
+	}
+	limiter.collector.InflightGaugeVector.WithLabelValues(limiter.name).Set(float64(limiter.inflight))
+	limiter.collector.QueuedGaugeVector.WithLabelValues(limiter.name).Set(float64(len(limiter.waiters)))
+	limiter.collector.ConcurrencyLimitGaugeVector.WithLabelValues(limiter.name).Set(math.Floor(limiter.limit))
+}
+
+// concurrencyLimiter holds the global limiter and limiters of actions
+type concurrencyLimiter struct {
+	option    ConcurrencyOption
+	collector *ServerCollector
+	global    *aimdLimiter
+
+	mu      sync.Mutex
+	actions map[string]*aimdLimiter
+}
+
+func newConcurrencyLimiter(option ConcurrencyOption, collector *ServerCollector) *concurrencyLimiter {
+	limiter := &concurrencyLimiter{
+		option:    option,
+		collector: collector,
+		actions:   make(map[string]*aimdLimiter),
+	}
+	if option.MaxInflight > 0 {
+		limiter.global = newAIMDLimiter(GlobalLimiter, option.MaxInflight, &limiter.option, collector)
+	}
+	return limiter
+}
+
+// acquireGlobal take a slot of all requests, release must be called when request is finished
+func (limiter *concurrencyLimiter) acquireGlobal(ctx context.Context) (release func(), err error) {
+	return limiter.acquire(ctx, limiter.global)
+}
+
+// acquireAction take a slot of action, release must be called when controller is finished
+func (limiter *concurrencyLimiter) acquireAction(ctx context.Context, action string) (release func(), err error) {
+	return limiter.acquire(ctx, limiter.actionLimiter(action))
+}
+
+func (limiter *concurrencyLimiter) actionLimiter(action string) *aimdLimiter {
+	max, ok := limiter.option.ActionInflight[action]
+	if !ok {
+		max = limiter.option.MaxActionInflight
+	}
+	if max <= 0 {
+		return nil
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	actionLimiter, ok := limiter.actions[action]
+	if !ok {
+		actionLimiter = newAIMDLimiter(action, max, &limiter.option, limiter.collector)
+		limiter.actions[action] = actionLimiter
+	}
+	return actionLimiter
+}
+
+func (limiter *concurrencyLimiter) acquire(ctx context.Context, aimd *aimdLimiter) (func(), error) {
+	if aimd == nil {
+		return func() {}, nil
+	}
+	if err := aimd.acquire(ctx); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	return func() {
+		dropped := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if target := limiter.option.TargetLatency; target > 0 && time.Since(start) > target {
+			dropped = true
+		}
+		aimd.release(dropped)
+	}, nil
+}
@@ -0,0 +1,173 @@
+package framework
+
+import (
+	"context"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	option := &ConcurrencyOption{QueueSize: 1}
+	limiter := newAIMDLimiter("test", 2, option, NewCollector())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.acquire(ctx); err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+	}
+
+	queued := make(chan error)
+	go func() { queued <- limiter.acquire(ctx) }()
+	for testutil.ToFloat64(limiter.collector.QueuedGaugeVector.WithLabelValues("test")) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := limiter.acquire(ctx); err == nil {
+		t.Fatal("acquire should be shed when queue is full")
+	}
+
+	// slot is handed over to the queued request
+	limiter.release(false)
+	if err := <-queued; err != nil {
+		t.Fatalf("queued acquire: %v", err)
+	}
+	if limiter.inflight != 2 {
+		t.Errorf("inflight = %d, want 2", limiter.inflight)
+	}
+
+	// limit is decreased by dropped request, and increased back by the next one
+	limiter.release(true)
+	if int(limiter.limit) != 1 {
+		t.Errorf("limit = %v, want 1.8", limiter.limit)
+	}
+	limiter.release(false)
+	if limiter.inflight != 0 || limiter.limit != 2 {
+		t.Errorf("inflight = %d, limit = %v", limiter.inflight, limiter.limit)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	limiter.limit = 1
+	_ = limiter.acquire(ctx)
+	if err := limiter.acquire(timeoutCtx); err == nil {
+		t.Error("queued acquire should be shed when request is canceled")
+	}
+	if len(limiter.waiters) != 0 {
+		t.Errorf("waiters = %d, want 0", len(limiter.waiters))
+	}
+}
+
+func TestAIMDLimiter_releaseWakesAllowedWaiters(t *testing.T) {
+	option := &ConcurrencyOption{QueueSize: 2}
+	limiter := newAIMDLimiter("test", 3, option, NewCollector())
+	ctx := context.Background()
+
+	limiter.limit = 1.9
+	_ = limiter.acquire(ctx)
+	queued := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { queued <- limiter.acquire(ctx) }()
+	}
+	for testutil.ToFloat64(limiter.collector.QueuedGaugeVector.WithLabelValues("test")) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// limit is increased to 2 while the slot is released, both queued requests fit in
+	limiter.release(false)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-queued:
+			if err != nil {
+				t.Fatalf("queued acquire: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("queued request is not woken up")
+		}
+	}
+	if limiter.inflight != 2 || len(limiter.waiters) != 0 {
+		t.Errorf("inflight = %d, waiters = %d", limiter.inflight, len(limiter.waiters))
+	}
+}
+
+func TestServer_ConcurrencyLimit(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					close(started)
+					<-finish
+					return &testResponse{}, nil
+				},
+			}
+		},
+	}, ServerOption{ConcurrencyOption: ConcurrencyOption{MaxInflight: 10, MaxActionInflight: 1}})
+
+	const body = `{"Action":"DescribeTest","Name":"a","Limit":1}`
+	done := make(chan testReply)
+	go func() { done <- doRequest(t, s.Handler(), body) }()
+	<-started
+
+	reply := doRequest(t, s.Handler(), body)
+	if reply.Response.Error == nil || reply.Response.Error.Code != "ResourceInsufficient" {
+		t.Errorf("unexpected reply %+v, want ResourceInsufficient", reply.Response)
+	}
+	collector := s.Collector()
+	if got := testutil.ToFloat64(collector.InflightGaugeVector.WithLabelValues("DescribeTest")); got != 1 {
+		t.Errorf("inflight of action = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(collector.ShedCounterVector.WithLabelValues("DescribeTest")); got != 1 {
+		t.Errorf("shed of action = %v, want 1", got)
+	}
+
+	close(finish)
+	if reply := <-done; reply.Response.Error != nil {
+		t.Errorf("unexpected error %+v", *reply.Response.Error)
+	}
+	if got := testutil.ToFloat64(collector.InflightGaugeVector.WithLabelValues(GlobalLimiter)); got != 0 {
+		t.Errorf("inflight of server = %v, want 0", got)
+	}
+}
+
+func TestServer_GlobalConcurrencyLimitAfterTimeout(t *testing.T) {
+	finish, finished := make(chan struct{}), make(chan struct{})
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					defer close(finished)
+					<-finish
+					return &testResponse{}, nil
+				},
+			}
+		},
+	}, ServerOption{
+		ActionTimeouts:    map[string]time.Duration{"DescribeTest": 20 * time.Millisecond},
+		ConcurrencyOption: ConcurrencyOption{MaxInflight: 1},
+	})
+
+	const body = `{"Action":"DescribeTest","Name":"a","Limit":1}`
+	reply := doRequest(t, s.Handler(), body)
+	if reply.Response.Error == nil || reply.Response.Error.Code != "RequestTimeout" {
+		t.Fatalf("unexpected reply %+v, want RequestTimeout", reply.Response)
+	}
+
+	// slot is held by the controller still running
+	reply = doRequest(t, s.Handler(), body)
+	if reply.Response.Error == nil || reply.Response.Error.Code != "ResourceInsufficient" {
+		t.Errorf("unexpected reply %+v, want ResourceInsufficient", reply.Response)
+	}
+	if reply.Response.RequestId == "" {
+		t.Error("RequestId of shed request is empty")
+	}
+
+	close(finish)
+	<-finished
+	s.inflight.Wait()
+	gauge := s.Collector().InflightGaugeVector.WithLabelValues(GlobalLimiter)
+	if got := testutil.ToFloat64(gauge); got != 0 {
+		t.Errorf("inflight of server = %v, want 0", got)
+	}
+}
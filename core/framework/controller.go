@@ -1,6 +1,9 @@
 package framework
 
-import "github.com/SongOf/edge-storage-core/core"
+import (
+	"github.com/SongOf/edge-storage-core/core"
+	"time"
+)
 
 type ControllerFactory interface {
 	GetController(string) Controller
@@ -11,6 +14,18 @@ type Controller interface {
 	Entry(ctx *core.Context) (ControllerResult, error)
 }
 
+// TimeoutController is an optional interface of Controller
+// Timeout overrides ActionMeta.Timeout, ServerOption.RequestTimeout and ServerOption.ActionTimeouts
+type TimeoutController interface {
+	Timeout() time.Duration
+}
+
+// ResourceController is an optional interface of Controller, which declares resources accessed by the request.
+// GetResources is called after parameters are bound, resources are authorized by NewAuthzMiddleware.
+type ResourceController interface {
+	GetResources(ctx *core.Context) ([]Resource, error)
+}
+
 type ControllerDescription interface {
 	Spec()
 }
@@ -21,6 +36,8 @@ type ControllerResult interface {
 
 type BaseDescription struct {
 	Action    string
+	Version   string
+	Language  string
 	RequestId string
 }
 
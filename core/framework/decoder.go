@@ -0,0 +1,347 @@
+package framework
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"math"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MIMEJSON        = "application/json"
+	MIMEForm        = "application/x-www-form-urlencoded"
+	MIMEMsgpack     = "application/msgpack"
+	MIMEXMsgpack    = "application/x-msgpack"
+	MIMECBOR        = "application/cbor"
+	flattenedKeySep = "."
+)
+
+// RequestDecoder decode request body of a content type to params
+type RequestDecoder interface {
+	Decode(body []byte) (map[string]interface{}, error)
+	// WeaklyTyped report whether values are decoded as strings,
+	// which are converted to number and boolean fields on binding
+	WeaklyTyped() bool
+}
+
+// DecodedRequest is request decoded by RequestDecoder
+type DecodedRequest struct {
+	// Params are params of body merged with query params, numbers are float64 like encoding/json
+	Params map[string]interface{}
+	// Body is the raw body of json requests, it's nil for other content types
+	Body        []byte
+	WeaklyTyped bool
+
+	// values are params of body decoded by RequestDecoder, numbers are json.Number
+	values map[string]interface{}
+	// query are query params absent from body, which are bound weakly
+	query map[string]interface{}
+}
+
+func defaultDecoders() map[string]RequestDecoder {
+	return map[string]RequestDecoder{
+		MIMEForm:     formDecoder{},
+		MIMEMsgpack:  msgpackDecoder{},
+		MIMEXMsgpack: msgpackDecoder{},
+		MIMECBOR:     cborDecoder{},
+	}
+}
+
+// RegisterDecoder register decoder for contentType, decoders of the same content type are replaced
+func (parser *Parser) RegisterDecoder(contentType string, decoder RequestDecoder) {
+	parser.decoders[contentType] = decoder
+}
+
+// DecodeRequest decode request body by Content-Type, and merge query params absent from body.
+// Requests with json, empty or unknown Content-Type are decoded as json, unless a decoder
+// of the content type is registered. Body of GET requests is ignored.
+func (parser *Parser) DecodeRequest(r *http.Request, body []byte) (*DecodedRequest, error) {
+	query, err := formDecoder{}.Decode([]byte(r.URL.RawQuery))
+	if err != nil {
+		return nil, err
+	}
+
+	request := &DecodedRequest{WeaklyTyped: true}
+	if r.Method != http.MethodGet {
+		if request, err = parser.decodeBody(r, body); err != nil {
+			return nil, err
+		}
+	}
+	request.mergeQuery(query)
+	return request, nil
+}
+
+// decodeBody decode body by decoder of Content-Type
+func (parser *Parser) decodeBody(r *http.Request, body []byte) (*DecodedRequest, error) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	decoder, ok := parser.decoders[contentType]
+	if !ok {
+		params, err := parser.PreParseRequest(body)
+		if err != nil {
+			return nil, err
+		}
+		return &DecodedRequest{Params: params, Body: body}, nil
+	}
+
+	params, err := decoder.Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	values, err := normalizeObject(params)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedRequest{
+		Params:      numberToFloat(values).(map[string]interface{}),
+		WeaklyTyped: decoder.WeaklyTyped(),
+		values:      values,
+	}, nil
+}
+
+// mergeQuery merge query params absent from body into Params, params of body take precedence
+func (request *DecodedRequest) mergeQuery(query map[string]interface{}) {
+	params := make(map[string]interface{}, len(query)+len(request.Params))
+	request.query = make(map[string]interface{}, len(query))
+	for key, value := range query {
+		if _, exists := request.Params[key]; !exists {
+			params[key] = value
+			request.query[key] = value
+		}
+	}
+	for key, value := range request.Params {
+		params[key] = value
+	}
+	request.Params = params
+}
+
+// normalizeObject normalize values of object, see normalizeValue
+func normalizeObject(object map[string]interface{}) (map[string]interface{}, error) {
+	normalized := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		v, err := normalizeValue(value)
+		if err != nil {
+			return nil, err
+		}
+		normalized[key] = v
+	}
+	return normalized, nil
+}
+
+// normalizeValue convert value decoded by RequestDecoder to the types decoded from json,
+// so that params of all content types are bound alike: numbers like int8 and uint64 are
+// converted to json.Number, binary to base64 string, and time to RFC 3339 string
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string, json.Number:
+		return v, nil
+	case float32:
+		return floatNumber(float64(v), 32)
+	case float64:
+		return floatNumber(v, 64)
+	case big.Int:
+		return json.Number(v.String()), nil
+	case *big.Int:
+		return json.Number(v.String()), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]interface{}:
+		return normalizeObject(v)
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, unsupportedError(key)
+			}
+			object[name] = value
+		}
+		return normalizeObject(object)
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i := range v {
+			elem, err := normalizeValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			array[i] = elem
+		}
+		return array, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return json.Number(strconv.FormatUint(rv.Uint(), 10)), nil
+	}
+	return nil, unsupportedError(value)
+}
+
+// floatNumber convert float to json.Number, NaN and Inf are not allowed in json
+func floatNumber(f float64, bits int) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, unsupportedError(f)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bits)), nil
+}
+
+func unsupportedError(value interface{}) error {
+	return eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil).
+		Wrap(fmt.Errorf("unsupported value %v of type %T", value, value))
+}
+
+// formDecoder decode urlencoded form, keys in flattened notation like `Filters.0.Name` are expanded,
+// repeated keys are decoded as array
+type formDecoder struct{}
+
+func (formDecoder) Decode(body []byte) (map[string]interface{}, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil).Wrap(err)
+	}
+
+	flattened := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if len(value) == 1 {
+			flattened[key] = value[0]
+			continue
+		}
+		array := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			array = append(array, v)
+		}
+		flattened[key] = array
+	}
+	return Unflatten(flattened)
+}
+
+func (formDecoder) WeaklyTyped() bool {
+	return true
+}
+
+type msgpackDecoder struct{}
+
+func (msgpackDecoder) Decode(body []byte) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+	if err := msgpack.Unmarshal(body, &params); err != nil {
+		return nil, eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil).Wrap(err)
+	}
+	return params, nil
+}
+
+func (msgpackDecoder) WeaklyTyped() bool {
+	return false
+}
+
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+}.DecMode()
+
+type cborDecoder struct{}
+
+func (cborDecoder) Decode(body []byte) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+	if err := cborDecMode.Unmarshal(body, &params); err != nil {
+		return nil, eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil).Wrap(err)
+	}
+	return params, nil
+}
+
+func (cborDecoder) WeaklyTyped() bool {
+	return false
+}
+
+// Unflatten expand keys in flattened notation like `Filters.0.Name` to nested params,
+// objects whose keys are all indexes are converted to array ordered by index
+func Unflatten(flattened map[string]interface{}) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+	for key, value := range flattened {
+		node := params
+		path := strings.Split(key, flattenedKeySep)
+		for i, name := range path {
+			if i == len(path)-1 {
+				if _, exists := node[name]; exists {
+					return nil, conflictError(path[:i+1])
+				}
+				node[name] = value
+				break
+			}
+
+			child, exists := node[name]
+			if !exists {
+				child = make(map[string]interface{})
+				node[name] = child
+			}
+			childNode, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, conflictError(path[:i+1])
+			}
+			node = childNode
+		}
+	}
+	for key, value := range params {
+		params[key] = toArrays(value)
+	}
+	return params, nil
+}
+
+// toArrays convert objects whose keys are all indexes in value to array
+func toArrays(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	for key, child := range object {
+		object[key] = toArrays(child)
+	}
+
+	keys := make([]string, 0, len(object))
+	indexes := make(map[string]int, len(object))
+	for key := range object {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 {
+			return object
+		}
+		keys = append(keys, key)
+		indexes[key] = index
+	}
+	if len(keys) == 0 {
+		return object
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return indexes[keys[i]] < indexes[keys[j]]
+	})
+	array := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		array = append(array, object[key])
+	}
+	return array
+}
+
+// conflictError is returned when a flattened key is both a value and an object, like `A=1&A.0=2`
+func conflictError(path []string) error {
+	return eserrors.InvalidParameterValueEx(
+		eserrors.InvalidParameterValueTypeCode,
+		map[string]interface{}{
+			"parameter":  formatPath(path),
+			"actualType": jsonTypeString,
+			"expectType": jsonTypeObject,
+		},
+	)
+}
@@ -0,0 +1,375 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnflatten(t *testing.T) {
+	tests := []struct {
+		name      string
+		flattened map[string]interface{}
+		want      map[string]interface{}
+		wantErr   bool
+	}{
+		{
+			name: "nested objects and arrays",
+			flattened: map[string]interface{}{
+				"Action":             "DescribeTest",
+				"Filters.0.Name":     "zone",
+				"Filters.0.Values.0": "a",
+				"Filters.0.Values.1": "b",
+				"Filters.1.Name":     "name",
+				"Tags.k":             "v",
+			},
+			want: map[string]interface{}{
+				"Action": "DescribeTest",
+				"Filters": []interface{}{
+					map[string]interface{}{"Name": "zone", "Values": []interface{}{"a", "b"}},
+					map[string]interface{}{"Name": "name"},
+				},
+				"Tags": map[string]interface{}{"k": "v"},
+			},
+		},
+		{
+			name:      "indexes are ordered and compacted",
+			flattened: map[string]interface{}{"InstanceIds.10": "c", "InstanceIds.2": "b", "InstanceIds.1": "a"},
+			want:      map[string]interface{}{"InstanceIds": []interface{}{"a", "b", "c"}},
+		},
+		{
+			name:      "value conflicts with object",
+			flattened: map[string]interface{}{"Filters": "a", "Filters.0.Name": "zone"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unflatten(tt.flattened)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unflatten() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unflatten() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_DecodeRequest(t *testing.T) {
+	parser, _ := NewParser()
+	msgpackBody, _ := msgpack.Marshal(map[string]interface{}{"Limit": int8(10), "Uin": uint64(100000000001)})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        []byte
+		wantParams  map[string]interface{}
+		wantValues  map[string]interface{}
+		wantQuery   map[string]interface{}
+	}{
+		{
+			name:       "json numbers are float64",
+			method:     http.MethodPost,
+			target:     "/",
+			body:       []byte(`{"Limit":10,"Filters":[{"Values":[1.5]}]}`),
+			wantParams: map[string]interface{}{"Limit": float64(10), "Filters": []interface{}{map[string]interface{}{"Values": []interface{}{1.5}}}},
+			wantQuery:  map[string]interface{}{},
+		},
+		{
+			name:       "json body takes precedence over query",
+			method:     http.MethodPost,
+			target:     "/?Action=DescribeTest&Name=query",
+			body:       []byte(`{"Name":"body"}`),
+			wantParams: map[string]interface{}{"Action": "DescribeTest", "Name": "body"},
+			wantQuery:  map[string]interface{}{"Action": "DescribeTest"},
+		},
+		{
+			name:        "msgpack numbers are normalized",
+			method:      http.MethodPost,
+			target:      "/?Action=DescribeTest",
+			contentType: MIMEMsgpack,
+			body:        msgpackBody,
+			wantParams:  map[string]interface{}{"Action": "DescribeTest", "Limit": float64(10), "Uin": float64(100000000001)},
+			wantValues:  map[string]interface{}{"Limit": json.Number("10"), "Uin": json.Number("100000000001")},
+			wantQuery:   map[string]interface{}{"Action": "DescribeTest"},
+		},
+		{
+			name:       "body of GET is ignored",
+			method:     http.MethodGet,
+			target:     "/?Action=DescribeTest&Limit=10",
+			body:       []byte(`{"Limit":20}`),
+			wantParams: map[string]interface{}{"Action": "DescribeTest", "Limit": "10"},
+			wantQuery:  map[string]interface{}{"Action": "DescribeTest", "Limit": "10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			request, err := parser.DecodeRequest(r, tt.body)
+			if err != nil {
+				t.Fatalf("DecodeRequest() error = %v", err)
+			}
+			if !reflect.DeepEqual(request.Params, tt.wantParams) {
+				t.Errorf("Params = %v, want %v", request.Params, tt.wantParams)
+			}
+			if !reflect.DeepEqual(request.values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", request.values, tt.wantValues)
+			}
+			if !reflect.DeepEqual(request.query, tt.wantQuery) {
+				t.Errorf("query = %v, want %v", request.query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:  "integers",
+			value: []interface{}{int8(-1), uint64(18446744073709551615), big.NewInt(7)},
+			want:  []interface{}{json.Number("-1"), json.Number("18446744073709551615"), json.Number("7")},
+		},
+		{
+			name:  "floats",
+			value: []interface{}{float32(1.5), 0.1},
+			want:  []interface{}{json.Number("1.5"), json.Number("0.1")},
+		},
+		{
+			name:  "binary and time",
+			value: []interface{}{[]byte("edge"), time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+			want:  []interface{}{"ZWRnZQ==", "2021-01-02T03:04:05Z"},
+		},
+		{
+			name:  "object with interface keys",
+			value: map[interface{}]interface{}{"Limit": uint8(10)},
+			want:  map[string]interface{}{"Limit": json.Number("10")},
+		},
+		{
+			name:    "object with integer keys",
+			value:   map[interface{}]interface{}{1: "a"},
+			wantErr: true,
+		},
+		{
+			name:    "NaN",
+			value:   math.NaN(),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_ContentTypes(t *testing.T) {
+	factory := testFactory{
+		"DescribeTest": func() Controller { return &testController{} },
+	}
+	s := newTestServer(factory, ServerOption{})
+
+	params := map[string]interface{}{"Action": "DescribeTest", "Name": "edge", "Limit": 10}
+	msgpackBody, _ := msgpack.Marshal(params)
+	cborBody, _ := cbor.Marshal(params)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        []byte
+		wantCode    string
+	}{
+		{
+			name:   "json without content type",
+			method: http.MethodPost,
+			body:   []byte(`{"Action":"DescribeTest","Name":"edge","Limit":10}`),
+		},
+		{
+			name:        "form",
+			method:      http.MethodPost,
+			contentType: MIMEForm + "; charset=utf-8",
+			body:        []byte("Action=DescribeTest&Name=edge&Limit=10"),
+		},
+		{
+			name:   "query string",
+			method: http.MethodGet,
+			target: "/?Action=DescribeTest&Name=edge&Limit=10",
+		},
+		{
+			name:        "msgpack",
+			method:      http.MethodPost,
+			contentType: MIMEMsgpack,
+			body:        msgpackBody,
+		},
+		{
+			name:        "form with action in query",
+			method:      http.MethodPost,
+			target:      "/?Action=DescribeTest&Limit=ten",
+			contentType: MIMEForm,
+			body:        []byte("Name=edge&Limit=10"),
+		},
+		{
+			name:   "json with action in query",
+			method: http.MethodPost,
+			target: "/?Action=DescribeTest",
+			body:   []byte(`{"Name":"edge","Limit":10}`),
+		},
+		{
+			name:     "unknown query parameter",
+			method:   http.MethodPost,
+			target:   "/?Action=DescribeTest&Unknown=1",
+			body:     []byte(`{"Name":"edge","Limit":10}`),
+			wantCode: "UnknownParameter",
+		},
+		{
+			name:        "cbor",
+			method:      http.MethodPost,
+			contentType: MIMECBOR,
+			body:        cborBody,
+		},
+		{
+			name:        "form type error",
+			method:      http.MethodPost,
+			contentType: MIMEForm,
+			body:        []byte("Action=DescribeTest&Name=edge&Limit=ten"),
+			wantCode:    "InvalidParameterValue.Type",
+		},
+		{
+			name:        "form unknown flattened parameter",
+			method:      http.MethodPost,
+			contentType: MIMEForm,
+			body:        []byte("Action=DescribeTest&Name=edge&Limit=10&Filters.0.Name=zone"),
+			wantCode:    "UnknownParameter",
+		},
+		{
+			name:        "msgpack syntax error",
+			method:      http.MethodPost,
+			contentType: MIMEMsgpack,
+			body:        []byte{0xc1},
+			wantCode:    "InvalidParameter.Syntax",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target == "" {
+				target = "/"
+			}
+			r := httptest.NewRequest(tt.method, target, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			reply := serveRequest(t, s.Handler(), r)
+			if tt.wantCode == "" {
+				if reply.Response.Error != nil || reply.Response.Name != "edge" {
+					t.Errorf("unexpected reply %+v", reply.Response)
+				}
+				return
+			}
+			if reply.Response.Error == nil || reply.Response.Error.Code != tt.wantCode {
+				t.Errorf("unexpected reply %+v, want code %s", reply.Response, tt.wantCode)
+			}
+		})
+	}
+}
+
+type testUserInfoDescription struct {
+	BaseDescription
+	AppId         int64
+	Uin           uint64
+	SubAccountUin uint64
+}
+
+type testUserInfoController struct {
+	description testUserInfoDescription
+}
+
+func (c *testUserInfoController) GetDescription() ControllerDescription {
+	return &c.description
+}
+
+func (c *testUserInfoController) Entry(ctx *core.Context) (ControllerResult, error) {
+	userInfo := fmt.Sprintf("%d/%s/%s", ctx.UserInfo.AppId, ctx.UserInfo.Uin, ctx.UserInfo.SubAccountUin)
+	return &testResponse{Name: userInfo}, nil
+}
+
+func TestServer_ContentTypesLoadUserInfo(t *testing.T) {
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller { return &testUserInfoController{} },
+	}, ServerOption{Middlewares: []core.Middleware{NewLoadUserInfoMiddleware()}})
+
+	params := map[string]interface{}{
+		"Action":        "DescribeTest",
+		"AppId":         1251000000,
+		"Uin":           uint64(100000000001),
+		"SubAccountUin": int8(7),
+	}
+	msgpackBody, _ := msgpack.Marshal(params)
+	cborBody, _ := cbor.Marshal(params)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{
+			name: "json",
+			body: []byte(`{"Action":"DescribeTest","AppId":1251000000,"Uin":100000000001,"SubAccountUin":7}`),
+		},
+		{
+			name:        "msgpack",
+			contentType: MIMEMsgpack,
+			body:        msgpackBody,
+		},
+		{
+			name:        "cbor",
+			contentType: MIMECBOR,
+			body:        cborBody,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			reply := serveRequest(t, s.Handler(), r)
+			if reply.Response.Error != nil || reply.Response.Name != "1251000000/100000000001/7" {
+				t.Errorf("unexpected reply %+v", reply.Response)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package framework
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// GracefulFlag is the default command line argument passed to process started by Reload
+	GracefulFlag = "-graceful"
+	// DefaultListenerFD is the default fd of inherited listener in process started by Reload
+	DefaultListenerFD = 3
+	// DefaultReadyTimeout is the default time to wait for process started by Reload to be ready
+	DefaultReadyTimeout = 30 * time.Second
+
+	// ReadyFDEnv tells the reloaded process which fd to write ready message to
+	ReadyFDEnv = "ESCORE_READY_FD"
+
+	readyMessage = "ready"
+)
+
+type GracefulOption struct {
+	// Flag is the command line argument marks the process is started by Reload
+	Flag string
+	// ListenerFD is the fd of inherited listener, it must not be less than 3
+	ListenerFD int
+	// ReadyTimeout is the max time to wait for the reloaded process to be ready
+	ReadyTimeout time.Duration
+}
+
+func (option GracefulOption) flag() string {
+	if option.Flag == "" {
+		return GracefulFlag
+	}
+	return option.Flag
+}
+
+func (option GracefulOption) listenerFD() int {
+	if option.ListenerFD < DefaultListenerFD {
+		return DefaultListenerFD
+	}
+	return option.ListenerFD
+}
+
+func (option GracefulOption) readyTimeout() time.Duration {
+	if option.ReadyTimeout <= 0 {
+		return DefaultReadyTimeout
+	}
+	return option.ReadyTimeout
+}
+
+// Reload start a new process with listeners and wait for it to be ready, listeners
+// created by Start are handed over if no listener is given.
+// Reload returns nil only if the new process reported ready, caller should stop
+// serving after that. If the new process exited or was not ready in time, it's killed
+// and an error is returned, caller should keep serving.
+func (s *Server) Reload(listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		listeners = s.listeners
+	}
+	if len(listeners) == 0 {
+		return errors.New("no listener to hand over")
+	}
+
+	files, err := listenerFiles(listeners)
+	if err != nil {
+		return err
+	}
+	// files are dups of listener fds, child process holds its own copies after started
+	defer closeFiles(files)
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	option := s.Option.GracefulOption
+	// cmd.ExtraFiles: If non-nil, entry i becomes file descriptor 3+i.
+	// nil entries are closed in child process.
+	listenerIndex := option.listenerFD() - 3
+	readyFD := option.listenerFD() + len(files)
+	extraFiles := make([]*os.File, listenerIndex, readyFD-2)
+	extraFiles = append(extraFiles, files...)
+	extraFiles = append(extraFiles, readyWriter)
+
+	addrs := make([]string, 0, len(listeners))
+	for _, ln := range listeners {
+		addrs = append(addrs, s.listenAddr(ln))
+	}
+
+	cmd := exec.Command(os.Args[0], reloadArgs(option.flag())...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = extraFiles
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%d", ReadyFDEnv, readyFD),
+		fmt.Sprintf("%s=%s", ListenersEnv, strings.Join(addrs, ",")))
+	err = cmd.Start()
+	// close our copy of write end, so reading gets EOF once child process exits
+	_ = readyWriter.Close()
+	// cmd.Start put files in blocking mode, which is shared with listeners,
+	// a blocking listener can't be closed while accepting
+	for _, ln := range listeners {
+		if nonblockErr := setNonblock(ln.(fileListener)); nonblockErr != nil {
+			eslog.L().Warn("restore listener nonblocking mode failed", eslog.Err(nonblockErr))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	eslog.L().Info("reload process started", eslog.Field("Pid", cmd.Process.Pid))
+
+	// reap child process, or it will be a zombie if it exits before us
+	go func() {
+		err := cmd.Wait()
+		eslog.L().Info("reload process exited", eslog.Field("Pid", cmd.Process.Pid), eslog.Err(err))
+	}()
+
+	if err = waitReady(readyReader, option.readyTimeout()); err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+	eslog.L().Info("reload process is ready", eslog.Field("Pid", cmd.Process.Pid))
+
+	// unix socket files are in use by the new process now
+	for _, ln := range listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
+
+// waitReady wait for ready message from r, which is the read end of ready pipe of reloaded process
+func waitReady(r io.Reader, timeout time.Duration) error {
+	ready := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(r).ReadString('\n')
+		if strings.TrimSpace(line) == readyMessage {
+			ready <- nil
+		} else if err != nil {
+			ready <- fmt.Errorf("reload process exited before ready: %v", err)
+		} else {
+			ready <- fmt.Errorf("unexpected ready message %q", line)
+		}
+	}()
+
+	select {
+	case err := <-ready:
+		return err
+	case <-time.After(timeout):
+		return errors.New("wait reload process ready timeout")
+	}
+}
+
+// listenAddr return configured listen address of ln
+func (s *Server) listenAddr(ln net.Listener) string {
+	for i, listener := range s.listeners {
+		if listener == ln {
+			return s.listenAddrs[i]
+		}
+	}
+	return ln.Addr().Network() + "://" + ln.Addr().String()
+}
+
+func setNonblock(listener syscall.Conn) error {
+	rawConn, err := listener.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var nonblockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		nonblockErr = syscall.SetNonblock(int(fd), true)
+	})
+	if err != nil {
+		return err
+	}
+	return nonblockErr
+}
+
+// reloadArgs return arguments of current process with graceful flag
+func reloadArgs(flag string) []string {
+	args := []string{flag}
+	for _, arg := range os.Args[1:] {
+		if arg != flag {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// isGracefulChild report whether current process is started by Reload
+func (s *Server) isGracefulChild() bool {
+	flag := s.Option.GracefulOption.flag()
+	for _, arg := range os.Args[1:] {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyReady tell parent process that current process is serving
+func (s *Server) notifyReady() error {
+	rawFD := os.Getenv(ReadyFDEnv)
+	if rawFD == "" {
+		// parent doesn't support ready handshake, stop it directly
+		ppid := syscall.Getppid()
+		eslog.L().Info("ready fd not found, killing parent", eslog.Field("Pid", ppid))
+		return syscall.Kill(ppid, syscall.SIGTERM)
+	}
+
+	// don't leak ready fd to the process reloaded by us
+	_ = os.Unsetenv(ReadyFDEnv)
+	fd, err := strconv.Atoi(rawFD)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", ReadyFDEnv, err)
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.WriteString(readyMessage + "\n")
+	return err
+}
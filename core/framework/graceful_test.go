@@ -0,0 +1,160 @@
+package framework
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestGracefulOption(t *testing.T) {
+	tests := []struct {
+		name             string
+		option           GracefulOption
+		wantFlag         string
+		wantListenerFD   int
+		wantReadyTimeout time.Duration
+	}{
+		{
+			name:             "default",
+			wantFlag:         GracefulFlag,
+			wantListenerFD:   DefaultListenerFD,
+			wantReadyTimeout: DefaultReadyTimeout,
+		},
+		{
+			name:             "custom",
+			option:           GracefulOption{Flag: "-reload", ListenerFD: 5, ReadyTimeout: time.Second},
+			wantFlag:         "-reload",
+			wantListenerFD:   5,
+			wantReadyTimeout: time.Second,
+		},
+		{
+			name:             "stdio fd",
+			option:           GracefulOption{ListenerFD: 2, ReadyTimeout: -time.Second},
+			wantFlag:         GracefulFlag,
+			wantListenerFD:   DefaultListenerFD,
+			wantReadyTimeout: DefaultReadyTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.option.flag(); got != tt.wantFlag {
+				t.Errorf("flag() = %s, want %s", got, tt.wantFlag)
+			}
+			if got := tt.option.listenerFD(); got != tt.wantListenerFD {
+				t.Errorf("listenerFD() = %d, want %d", got, tt.wantListenerFD)
+			}
+			if got := tt.option.readyTimeout(); got != tt.wantReadyTimeout {
+				t.Errorf("readyTimeout() = %s, want %s", got, tt.wantReadyTimeout)
+			}
+		})
+	}
+}
+
+func TestReloadArgs(t *testing.T) {
+	args := os.Args
+	defer func() { os.Args = args }()
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantArgs  []string
+		wantChild bool
+	}{
+		{
+			name:     "first start",
+			args:     []string{"es", "-c", "es.conf"},
+			wantArgs: []string{GracefulFlag, "-c", "es.conf"},
+		},
+		{
+			name:      "reloaded process",
+			args:      []string{"es", GracefulFlag, "-c", "es.conf"},
+			wantArgs:  []string{GracefulFlag, "-c", "es.conf"},
+			wantChild: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = tt.args
+			if got := reloadArgs(GracefulFlag); !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("reloadArgs() = %v, want %v", got, tt.wantArgs)
+			}
+			if got := (&Server{}).isGracefulChild(); got != tt.wantChild {
+				t.Errorf("isGracefulChild() = %v, want %v", got, tt.wantChild)
+			}
+		})
+	}
+}
+
+func TestNotifyReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// notifyReady closes the fd it writes to, like the fd inherited by reloaded process
+	fd, err := syscall.Dup(int(w.Fd()))
+	_ = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(ReadyFDEnv)
+	if err := os.Setenv(ReadyFDEnv, strconv.Itoa(fd)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&Server{}).notifyReady(); err != nil {
+		t.Fatalf("notifyReady() error = %v", err)
+	}
+	if err := waitReady(r, time.Second); err != nil {
+		t.Errorf("waitReady() error = %v", err)
+	}
+	if _, ok := os.LookupEnv(ReadyFDEnv); ok {
+		t.Errorf("%s is leaked to process reloaded later", ReadyFDEnv)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(w *os.File)
+		wantErr bool
+	}{
+		{
+			name:  "ready",
+			write: func(w *os.File) { _, _ = w.WriteString(readyMessage + "\n") },
+		},
+		{
+			name:    "exited before ready",
+			write:   func(w *os.File) { _ = w.Close() },
+			wantErr: true,
+		},
+		{
+			name:    "unexpected message",
+			write:   func(w *os.File) { _, _ = w.WriteString("failed\n") },
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			write:   func(w *os.File) {},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			defer w.Close()
+
+			tt.write(w)
+			if err := waitReady(r, 50*time.Millisecond); (err != nil) != tt.wantErr {
+				t.Errorf("waitReady() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/SongOf/edge-storage-core/storage/cache"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ParamClientToken         = "ClientToken"
+	DefaultClientTokenHeader = "X-TC-ClientToken"
+
+	DefaultIdempotencyTTL     = 24 * time.Hour
+	DefaultIdempotencyLockTTL = 5 * time.Minute
+
+	idempotencyKeyPrefix = "idempotency:"
+)
+
+// IdempotencyState is the state of a ClientToken in IdempotencyStore
+type IdempotencyState int
+
+const (
+	// IdempotencyStarted means the token is new, and it's marked in progress by the caller
+	IdempotencyStarted IdempotencyState = iota
+	// IdempotencyInProgress means the first request of the token is in progress
+	IdempotencyInProgress
+	// IdempotencyFinished means the first request of the token succeeded, and its response is stored
+	IdempotencyFinished
+)
+
+// IdempotencyStore stores responses of requests keyed by ClientToken
+type IdempotencyStore interface {
+	// Begin mark key in progress for lockTTL if key is new, or return state of key and the stored response
+	Begin(ctx context.Context, key string, lockTTL time.Duration) (IdempotencyState, []byte, error)
+	// Finish store response of key for ttl
+	Finish(ctx context.Context, key string, response []byte, ttl time.Duration) error
+	// Abort remove key in progress, so that the request can be retried
+	Abort(ctx context.Context, key string) error
+}
+
+type idempotencyEntry struct {
+	finished bool
+	response []byte
+	expire   time.Time
+}
+
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	nextPurge time.Time
+}
+
+// NewMemoryIdempotencyStore create IdempotencyStore in memory, which only dedupes requests to the same process
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{entries: make(map[string]*idempotencyEntry)}
+}
+
+const memoryIdempotencyPurgeInterval = time.Minute
+
+func (store *memoryIdempotencyStore) Begin(_ context.Context, key string, lockTTL time.Duration) (
+	IdempotencyState, []byte, error) {
+
+	now := time.Now()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if now.After(store.nextPurge) {
+		for k, entry := range store.entries {
+			if now.After(entry.expire) {
+				delete(store.entries, k)
+			}
+		}
+		store.nextPurge = now.Add(memoryIdempotencyPurgeInterval)
+	}
+
+	if entry, ok := store.entries[key]; ok && !now.After(entry.expire) {
+		if entry.finished {
+			return IdempotencyFinished, entry.response, nil
+		}
+		return IdempotencyInProgress, nil, nil
+	}
+	store.entries[key] = &idempotencyEntry{expire: now.Add(lockTTL)}
+	return IdempotencyStarted, nil, nil
+}
+
+func (store *memoryIdempotencyStore) Finish(_ context.Context, key string, response []byte, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.entries[key] = &idempotencyEntry{finished: true, response: response, expire: time.Now().Add(ttl)}
+	return nil
+}
+
+func (store *memoryIdempotencyStore) Abort(_ context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.entries, key)
+	return nil
+}
+
+// idempotencyBeginScript return state and response of key, or mark key in progress if it's new
+var idempotencyBeginScript = redis.NewScript(`
+local entry = redis.call("HMGET", KEYS[1], "state", "response")
+if entry[1] then
+	return {tonumber(entry[1]), entry[2] or ""}
+end
+redis.call("HSET", KEYS[1], "state", ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return {-1, ""}
+`)
+
+type redisIdempotencyStore struct {
+	client redis.Cmdable
+}
+
+// NewRedisIdempotencyStore create IdempotencyStore on redis, which dedupes requests to all processes
+func NewRedisIdempotencyStore(redisCache *cache.RedisCache) IdempotencyStore {
+	return &redisIdempotencyStore{client: redisCache.GetClient()}
+}
+
+func (store *redisIdempotencyStore) Begin(ctx context.Context, key string, lockTTL time.Duration) (
+	IdempotencyState, []byte, error) {
+
+	value, err := idempotencyBeginScript.Run(ctx, store.client, []string{key},
+		int(IdempotencyInProgress), lockTTL.Milliseconds()).Result()
+	if err != nil {
+		storage.CacheErrorInc()
+		return 0, nil, err
+	}
+	result, ok := value.([]interface{})
+	if !ok || len(result) != 2 {
+		return 0, nil, fmt.Errorf("unexpected result of idempotency script: %v", value)
+	}
+	state, _ := result[0].(int64)
+	if state < 0 {
+		return IdempotencyStarted, nil, nil
+	}
+	response, _ := result[1].(string)
+	return IdempotencyState(state), []byte(response), nil
+}
+
+func (store *redisIdempotencyStore) Finish(ctx context.Context, key string, response []byte, ttl time.Duration) error {
+	pipe := store.client.TxPipeline()
+	pipe.HSet(ctx, key, "state", int(IdempotencyFinished), "response", response)
+	pipe.PExpire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		storage.CacheErrorInc()
+		return err
+	}
+	return nil
+}
+
+func (store *redisIdempotencyStore) Abort(ctx context.Context, key string) error {
+	if err := store.client.Del(ctx, key).Err(); err != nil {
+		storage.CacheErrorInc()
+		return err
+	}
+	return nil
+}
+
+type IdempotencyOption struct {
+	// Store is the backend of responses, default is NewMemoryIdempotencyStore()
+	Store IdempotencyStore
+	// Actions are glob patterns of actions deduped by ClientToken, like `Create*`, empty means all actions
+	Actions []string
+	// Header is the header of ClientToken, default is DefaultClientTokenHeader, `-` means disabled
+	Header string
+	// TTL is the time to keep responses, default is DefaultIdempotencyTTL
+	TTL time.Duration
+	// LockTTL is the max time of the first request in progress, default is DefaultIdempotencyLockTTL
+	LockTTL time.Duration
+}
+
+type idempotencyMiddleware struct {
+	option IdempotencyOption
+}
+
+// NewIdempotencyMiddleware create middleware which dedupes requests with the same ClientToken.
+// The first successful response of (AppId, Action, ClientToken) is stored and replied to retries
+// with the original RequestId, and retries are rejected while the first request is in progress.
+// ClientToken is read from header, or parameter `ClientToken` which must be declared by ControllerDescription.
+func NewIdempotencyMiddleware(option IdempotencyOption) core.Middleware {
+	if option.Store == nil {
+		option.Store = NewMemoryIdempotencyStore()
+	}
+	if option.Header == "" {
+		option.Header = DefaultClientTokenHeader
+	}
+	if option.TTL <= 0 {
+		option.TTL = DefaultIdempotencyTTL
+	}
+	if option.LockTTL <= 0 {
+		option.LockTTL = DefaultIdempotencyLockTTL
+	}
+	return &idempotencyMiddleware{option: option}
+}
+
+func (middleware *idempotencyMiddleware) Run(ctx *core.Context) error {
+	token := middleware.clientToken(ctx)
+	if token == "" || (len(middleware.option.Actions) > 0 && !matchAny(middleware.option.Actions, ctx.Action)) {
+		return ctx.Next()
+	}
+	value, ok := ctx.Get(ResponseKey)
+	if !ok {
+		return ctx.Next()
+	}
+	resp := value.(*ServerResponse)
+
+	key := idempotencyKeyPrefix + strconv.Itoa(ctx.UserInfo.AppId) + ":" + ctx.Action + ":" + token
+	state, response, err := middleware.option.Store.Begin(ctx, key, middleware.option.LockTTL)
+	if err != nil {
+		// requests are not blocked by the store, but retries might be applied twice
+		eslog.C(ctx).Error("begin idempotent request failed", eslog.Field("Key", key), eslog.Err(err))
+		return ctx.Next()
+	}
+
+	switch state {
+	case IdempotencyInProgress:
+		err := eserrors.RequestInProgress(token)
+		ctx.Error = err
+		return err
+	case IdempotencyFinished:
+		eslog.C(ctx).Info("reply stored response", eslog.Field("ClientToken", token))
+		resp.derive().WithResult(storedResult(response)).Reply()
+		return nil
+	}
+
+	// context of request may be canceled, the store is updated anyway
+	storeCtx := context.Background()
+	returned := false
+	defer func() {
+		// abort if controller panics, so that retries are not rejected until lock expires
+		if !returned {
+			middleware.abort(ctx, storeCtx, key)
+		}
+	}()
+	err = ctx.Next()
+	returned = true
+	if err != nil || ctx.Error != nil {
+		middleware.abort(ctx, storeCtx, key)
+		return err
+	}
+	content, marshalErr := json.Marshal(resp.Content)
+	if marshalErr == nil {
+		marshalErr = middleware.option.Store.Finish(storeCtx, key, content, middleware.option.TTL)
+	}
+	if marshalErr != nil {
+		eslog.C(ctx).Error("finish idempotent request failed", eslog.Field("Key", key), eslog.Err(marshalErr))
+	}
+	return nil
+}
+
+func (middleware *idempotencyMiddleware) abort(ctx *core.Context, storeCtx context.Context, key string) {
+	if err := middleware.option.Store.Abort(storeCtx, key); err != nil {
+		eslog.C(ctx).Error("abort idempotent request failed", eslog.Field("Key", key), eslog.Err(err))
+	}
+}
+
+func (middleware *idempotencyMiddleware) clientToken(ctx *core.Context) string {
+	if middleware.option.Header != disabledHeader && ctx.Request != nil {
+		if token := ctx.Request.Header.Get(middleware.option.Header); token != "" {
+			return token
+		}
+	}
+	token, _ := ctx.Params[ParamClientToken].(string)
+	return token
+}
+
+// storedResult is a stored response, which keeps the original RequestId
+type storedResult json.RawMessage
+
+func (result storedResult) WithRequestId(string) ControllerResult {
+	return result
+}
+
+func (result storedResult) MarshalJSON() ([]byte, error) {
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package framework
+
+import (
+	"errors"
+	"github.com/SongOf/edge-storage-core/core"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var calls int32
+	started, finish := make(chan struct{}), make(chan struct{})
+	s := newTestServer(testFactory{
+		"CreateTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					atomic.AddInt32(&calls, 1)
+					switch description.Name {
+					case "slow":
+						close(started)
+						<-finish
+					case "fail":
+						return nil, errors.New("create failed")
+					case "panic":
+						panic("create panicked")
+					}
+					return &testResponse{Name: description.Name}, nil
+				},
+			}
+		},
+	}, ServerOption{Middlewares: []core.Middleware{NewIdempotencyMiddleware(IdempotencyOption{Actions: []string{"Create*"}})}})
+
+	create := func(name, token string) testReply {
+		return doRequest(t, s.Handler(), `{"Action":"CreateTest","Name":"`+name+`","Limit":1}`,
+			DefaultClientTokenHeader, token)
+	}
+
+	first := create("a", "token-1")
+	replayed := create("a", "token-1")
+	if replayed.Response.Error != nil || replayed.Response.Name != "a" {
+		t.Fatalf("unexpected reply %+v", replayed.Response)
+	}
+	if replayed.Response.RequestId != first.Response.RequestId {
+		t.Errorf("RequestId = %s, want the original %s", replayed.Response.RequestId, first.Response.RequestId)
+	}
+	if calls != 1 {
+		t.Errorf("controller called %d times, want 1", calls)
+	}
+
+	// failed request is not stored
+	create("fail", "token-2")
+	create("fail", "token-2")
+	if calls != 3 {
+		t.Errorf("controller called %d times, want 3", calls)
+	}
+
+	// request panicked is aborted, retry is not rejected as in progress
+	create("panic", "token-4")
+	if reply := create("panic", "token-4"); reply.Response.Error == nil ||
+		reply.Response.Error.Code != "InternalError" {
+		t.Errorf("unexpected reply %+v, want InternalError", reply.Response)
+	}
+	if calls != 5 {
+		t.Errorf("controller called %d times, want 5", calls)
+	}
+
+	done := make(chan testReply)
+	go func() { done <- create("slow", "token-3") }()
+	<-started
+	if reply := create("slow", "token-3"); reply.Response.Error == nil ||
+		reply.Response.Error.Code != "RequestInProgress" {
+		t.Errorf("unexpected reply %+v, want RequestInProgress", reply.Response)
+	}
+	close(finish)
+	<-done
+	if reply := create("slow", "token-3"); reply.Response.Error != nil || reply.Response.Name != "slow" {
+		t.Errorf("unexpected reply %+v", reply.Response)
+	}
+	if calls != 6 {
+		t.Errorf("controller called %d times, want 6", calls)
+	}
+
+	// ClientToken in body is not declared by description
+	for i := 0; i < 2; i++ {
+		reply := doRequest(t, s.Handler(), `{"Action":"CreateTest","Name":"b","Limit":1,"ClientToken":"token-5"}`)
+		if reply.Response.Error != nil || reply.Response.Name != "b" {
+			t.Errorf("unexpected reply %+v", reply.Response)
+		}
+	}
+	if calls != 7 {
+		t.Errorf("controller called %d times, want 7", calls)
+	}
+}
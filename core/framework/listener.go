@@ -0,0 +1,180 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	SchemeTCP  = "tcp"
+	SchemeUnix = "unix"
+
+	// ListenersEnv tells the reloaded process listen addresses of inherited listeners, in fd order
+	ListenersEnv = "ESCORE_LISTENERS"
+)
+
+// fileListener is a net.Listener which can be handed over to another process
+type fileListener interface {
+	net.Listener
+	syscall.Conn
+	File() (*os.File, error)
+}
+
+// listenAddrs return all listen addresses, ListenAddr is the first one if not empty
+func (option *ServerOption) listenAddrs() []string {
+	addrs := make([]string, 0, len(option.ListenAddrs)+1)
+	if option.ListenAddr != "" {
+		addrs = append(addrs, option.ListenAddr)
+	}
+	return append(addrs, option.ListenAddrs...)
+}
+
+// parseListenAddr split addr like `tcp://0.0.0.0:80` or `unix:///var/run/es.sock`
+// to network and address, addr without scheme is a tcp address
+func parseListenAddr(addr string) (network, address string, err error) {
+	parts := strings.SplitN(addr, "://", 2)
+	if len(parts) == 1 {
+		return SchemeTCP, addr, nil
+	}
+
+	switch parts[0] {
+	case SchemeTCP, SchemeUnix:
+		if parts[1] == "" {
+			return "", "", fmt.Errorf("listen address `%s` is empty", addr)
+		}
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("unsupported scheme of listen address `%s`", addr)
+	}
+}
+
+// listen create listener on addr, stale unix socket file is removed before listening
+func listen(addr string) (net.Listener, error) {
+	network, address, err := parseListenAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == SchemeUnix {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// removeStaleSocket remove unix socket file left by a crashed process,
+// socket file in use is kept, and listening on it will fail later
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("`%s` exists and is not a unix socket", path)
+	}
+
+	if conn, err := net.Dial(SchemeUnix, path); err == nil {
+		_ = conn.Close()
+		return nil
+	}
+	eslog.L().Info("remove stale unix socket", eslog.Field("Path", path))
+	return os.Remove(path)
+}
+
+// inheritListeners create listeners from fds passed by parent process
+// listeners are returned in the same order as addrs, addresses not inherited are listened newly
+func inheritListeners(firstFD int, addrs []string) ([]net.Listener, error) {
+	inherited := make(map[string]net.Listener)
+	closeInherited := func() {
+		for _, ln := range inherited {
+			closeListeners(ln)
+		}
+	}
+	rawAddrs := os.Getenv(ListenersEnv)
+	if rawAddrs == "" {
+		// parent doesn't tell listen addresses, inherit the first one only
+		rawAddrs = addrs[0]
+	}
+	for i, addr := range strings.Split(rawAddrs, ",") {
+		f := os.NewFile(uintptr(firstFD+i), addr)
+		ln, err := net.FileListener(f)
+		// Closing ln does not affect f, and closing f does not affect ln.
+		_ = f.Close()
+		if err != nil {
+			closeInherited()
+			return nil, fmt.Errorf("inherit listener of `%s` from fd %d: %v", addr, firstFD+i, err)
+		}
+		inherited[addr] = ln
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		if ln, ok := inherited[addr]; ok {
+			delete(inherited, addr)
+			// we own the unix socket file now, remove it when closed
+			if ul, ok := ln.(*net.UnixListener); ok {
+				ul.SetUnlinkOnClose(true)
+			}
+			listeners = append(listeners, ln)
+			continue
+		}
+
+		eslog.L().Info("listen address is not inherited, listen on it", eslog.Field("Address", addr))
+		ln, err := listen(addr)
+		if err != nil {
+			closeInherited()
+			closeListeners(listeners...)
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+
+	// listeners removed from config, the parent process will close them too
+	closeInherited()
+	return listeners, nil
+}
+
+// closeListeners close listeners without removing unix socket files,
+// which may be in use by another process
+func closeListeners(listeners ...net.Listener) {
+	for _, ln := range listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		_ = ln.Close()
+	}
+}
+
+// listenerFiles dup fds of listeners to hand them over to another process
+func listenerFiles(listeners []net.Listener) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(listeners))
+	for _, ln := range listeners {
+		fl, ok := ln.(fileListener)
+		if !ok {
+			closeFiles(files)
+			return nil, errors.New("listener can not be handed over: " + ln.Addr().String())
+		}
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,115 @@
+package framework
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestParseListenAddr(t *testing.T) {
+	tests := []struct {
+		addr        string
+		wantNetwork string
+		wantAddress string
+		wantErr     bool
+	}{
+		{addr: ":8080", wantNetwork: "tcp", wantAddress: ":8080"},
+		{addr: "tcp://0.0.0.0:80", wantNetwork: "tcp", wantAddress: "0.0.0.0:80"},
+		{addr: "unix:///var/run/es.sock", wantNetwork: "unix", wantAddress: "/var/run/es.sock"},
+		{addr: "unix://", wantErr: true},
+		{addr: "udp://0.0.0.0:53", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			network, address, err := parseListenAddr(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseListenAddr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if network != tt.wantNetwork || address != tt.wantAddress {
+				t.Errorf("parseListenAddr() = %s, %s, want %s, %s",
+					network, address, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestListenStaleUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "es.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// leave socket file like a crashed process
+	closeListeners(stale)
+
+	ln, err := listen("unix://" + path)
+	if err != nil {
+		t.Fatalf("listen on stale socket: %v", err)
+	}
+	defer ln.Close()
+
+	// socket file in use must not be removed
+	if _, err := listen("unix://" + path); err == nil {
+		t.Error("listen on socket in use should fail")
+	}
+}
+
+func TestInheritListeners(t *testing.T) {
+	dir := t.TempDir()
+	tcpLn, err := listen("tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpLn.Close()
+	unixAddr := "unix://" + filepath.Join(dir, "es.sock")
+	unixLn, err := listen(unixAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(unixLn)
+	tcpAddr := "tcp://" + tcpLn.Addr().String()
+
+	// listeners are passed to reloaded process in consecutive fds
+	files, err := listenerFiles([]net.Listener{tcpLn, unixLn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFiles(files)
+	firstFD, err := syscall.Dup(int(files[0].Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondFD, err := syscall.Dup(int(files[1].Fd()))
+	if err != nil {
+		_ = syscall.Close(firstFD)
+		t.Fatal(err)
+	}
+	if secondFD != firstFD+1 {
+		_ = syscall.Close(firstFD)
+		_ = syscall.Close(secondFD)
+		t.Skip("fds are not consecutive")
+	}
+	defer os.Unsetenv(ListenersEnv)
+	if err := os.Setenv(ListenersEnv, tcpAddr+","+unixAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	newAddr := "unix://" + filepath.Join(dir, "new.sock")
+	listeners, err := inheritListeners(firstFD, []string{unixAddr, newAddr, tcpAddr})
+	if err != nil {
+		t.Fatalf("inheritListeners() error = %v", err)
+	}
+	defer closeListeners(listeners...)
+
+	want := []string{unixLn.Addr().String(), filepath.Join(dir, "new.sock"), tcpLn.Addr().String()}
+	if len(listeners) != len(want) {
+		t.Fatalf("got %d listeners, want %d", len(listeners), len(want))
+	}
+	for i, ln := range listeners {
+		if got := ln.Addr().String(); got != want[i] {
+			t.Errorf("listener %d address = %s, want %s", i, got, want[i])
+		}
+	}
+}
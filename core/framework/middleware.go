@@ -1,25 +1,25 @@
 package framework
 
 import (
+	"context"
+	"errors"
 	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
 	"github.com/SongOf/edge-storage-core/pkg/eslog"
 	"time"
 )
 
-type latencyMiddleware struct {
-	collector *ServerCollector
-}
+type latencyMiddleware struct{}
 
-func NewLatencyMiddleware(collector *ServerCollector) core.Middleware {
-	return &latencyMiddleware{collector: collector}
+// NewLatencyMiddleware return a middleware doing nothing, latency of requests is observed by server
+//
+// Deprecated: it's kept for compatibility, don't use it any more
+func NewLatencyMiddleware(*ServerCollector) core.Middleware {
+	return latencyMiddleware{}
 }
 
-func (middleware *latencyMiddleware) Run(ctx *core.Context) error {
-	trackTime := time.Now()
-	err := ctx.Next()
-	elapsed := time.Since(trackTime) / time.Millisecond
-	middleware.collector.ControllerLatencyHistogramVector.WithLabelValues(ctx.Action).Observe(float64(elapsed))
-	return err
+func (middleware latencyMiddleware) Run(ctx *core.Context) error {
+	return ctx.Next()
 }
 
 type resultMiddleware struct {
@@ -35,7 +35,15 @@ func NewResultMiddleware(
 }
 
 func (middleware *resultMiddleware) Run(ctx *core.Context) error {
+	endController := ctx.StartSpan("Controller")
+	start := time.Now()
 	result, rawerr := middleware.controller.Entry(ctx)
+	endController(rawerr)
+	if middleware.collector != nil {
+		middleware.collector.ControllerLatencyHistogramVector.WithLabelValues(ctx.Action).
+			Observe(time.Since(start).Seconds())
+	}
+	ctx.Error = rawerr
 	resp := middleware.resp
 	if rawerr == nil {
 		resp.WithResult(result).Reply()
@@ -45,6 +53,10 @@ func (middleware *resultMiddleware) Run(ctx *core.Context) error {
 			// error count ++
 			middleware.collector.ControllerErrorCounterVector.WithLabelValues(ctx.Action).Inc()
 		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// controller gave up because of the deadline
+			rawerr = eserrors.RequestTimeout()
+		}
 		resp.WithError(rawerr).Reply()
 		return ctx.Next()
 	}
@@ -52,6 +64,8 @@ func (middleware *resultMiddleware) Run(ctx *core.Context) error {
 
 type loadUserInfoMiddleware struct{}
 
+// NewLoadUserInfoMiddleware load UserInfo from AppId/Uin/SubAccountUin in body, which are trusted without verification,
+// use NewAuthMiddleware to load UserInfo of authenticated requests
 func NewLoadUserInfoMiddleware() *loadUserInfoMiddleware {
 	return &loadUserInfoMiddleware{}
 }
@@ -0,0 +1,203 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	OpenAPIVersion        = "3.1.0"
+	openAPISchemaRef      = "#/components/schemas/"
+	openAPIErrorSchema    = "ErrorResponse"
+	openAPIUnversioned    = "unversioned"
+	openAPIResponseMedium = "application/json"
+)
+
+// ActionLister is an optional interface of ControllerFactory, which lists all actions it serves
+type ActionLister interface {
+	Actions() []string
+}
+
+// ResultController is an optional interface of Controller, which provides an empty
+// ControllerResult of the action to generate API document
+type ResultController interface {
+	GetResult() ControllerResult
+}
+
+type OpenAPIDocument struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       OpenAPIInfo          `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components OpenAPIComponents    `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas"`
+}
+
+type PathItem struct {
+	Post *Operation `json:"post,omitempty"`
+}
+
+type Operation struct {
+	OperationId string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *JSONSchema `json:"schema"`
+}
+
+// OpenAPI generate OpenAPI document of all actions of version, actions without version are documented
+// if version is empty. Factory of the version must implement ActionLister, like Registry.
+// Operations are keyed by RouteOption.PathPattern, or `/{Action}` if it's not set.
+func (s *Server) OpenAPI(version string) (*OpenAPIDocument, error) {
+	factory, err := s.router.versionFactory(version)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := factory.(ActionLister)
+	if !ok {
+		return nil, errors.New("actions of controller factory can't be listed")
+	}
+
+	docVersion := version
+	if docVersion == "" {
+		docVersion = openAPIUnversioned
+	}
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info:    OpenAPIInfo{Title: filepath.Base(os.Args[0]), Version: docVersion},
+		Paths:   make(map[string]*PathItem),
+	}
+	generator := NewSchemaGenerator(openAPISchemaRef)
+	generator.Schemas[openAPIErrorSchema] = errorResponseSchema()
+
+	for _, action := range lister.Actions() {
+		controller := factory.GetController(action)
+		if controller == nil {
+			continue
+		}
+		operation := s.actionOperation(generator, version, action, controller)
+		doc.Paths[s.actionPath(version, action)] = &PathItem{Post: operation}
+	}
+	doc.Components.Schemas = generator.Schemas
+	return doc, nil
+}
+
+// APIDocument return OpenAPI document of version in json, it's served by dashboard.NewOpenAPIPanel
+func (s *Server) APIDocument(version string) ([]byte, error) {
+	doc, err := s.OpenAPI(version)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func (s *Server) actionPath(version, action string) string {
+	pattern := s.Option.RouteOption.PathPattern
+	if !strings.Contains(pattern, "{"+ParamAction+"}") {
+		return "/" + action
+	}
+	path := strings.ReplaceAll(pattern, "{"+ParamAction+"}", action)
+	if version != "" {
+		path = strings.ReplaceAll(path, "{"+ParamVersion+"}", version)
+	}
+	return path
+}
+
+func (s *Server) actionOperation(generator *SchemaGenerator, version, action string,
+	controller Controller) *Operation {
+
+	meta, _ := s.router.ActionMeta(version, action)
+	operation := &Operation{
+		OperationId: action,
+		Summary:     meta.Description,
+		Deprecated:  meta.Deprecated,
+		RequestBody: &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				MIMEJSON: {Schema: generator.Generate(controller.GetDescription())},
+			},
+		},
+		Responses: map[string]*Response{
+			"200": {Description: fmt.Sprintf("Result of %s, or error", action)},
+		},
+	}
+
+	// Action is sent in header if it's not in path, body is the fallback
+	if !strings.Contains(s.Option.RouteOption.PathPattern, "{"+ParamAction+"}") {
+		if header, ok := s.Option.RouteOption.headers()[ParamAction]; ok {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name:   header,
+				In:     "header",
+				Schema: &JSONSchema{Type: jsonTypeString, Const: action},
+			})
+		}
+	}
+
+	result := &JSONSchema{}
+	if resultController, ok := controller.(ResultController); ok {
+		result = generator.Generate(resultController.GetResult())
+	}
+	operation.Responses["200"].Content = map[string]*MediaType{
+		openAPIResponseMedium: {Schema: &JSONSchema{
+			Type: jsonTypeObject,
+			Properties: map[string]*JSONSchema{
+				"Response": {OneOf: []*JSONSchema{result, {Ref: openAPISchemaRef + openAPIErrorSchema}}},
+			},
+			Required: []string{"Response"},
+		}},
+	}
+	return operation
+}
+
+// errorResponseSchema is schema of ErrorCodeWithRequestId
+func errorResponseSchema() *JSONSchema {
+	errorCode := func(fields ...string) *JSONSchema {
+		schema := &JSONSchema{Type: jsonTypeObject, Properties: make(map[string]*JSONSchema), Required: fields}
+		for _, field := range fields {
+			schema.Properties[field] = &JSONSchema{Type: jsonTypeString}
+		}
+		return schema
+	}
+	return &JSONSchema{
+		Type: jsonTypeObject,
+		Properties: map[string]*JSONSchema{
+			"Error":     errorCode("Code", "Message"),
+			"Errors":    {Type: jsonTypeArray, Items: errorCode("Code", "Message", "Field")},
+			"RequestId": {Type: jsonTypeString},
+		},
+		Required: []string{"Error", "RequestId"},
+	}
+}
@@ -0,0 +1,114 @@
+package framework
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testSchemaFilter struct {
+	Name   string   `json:"Name" validate:"required,oneof=zone region"`
+	Values []string `json:"Values" validate:"min=1,max=5,dive,max=60"`
+}
+
+type testSchemaDescription struct {
+	BaseDescription
+	Filters []testSchemaFilter `json:"Filters" validate:"dive"`
+	Offset  *int               `json:"Offset" validate:"omitempty,gte=0"`
+	Email   string             `json:"Email" validate:"required,email" description:"email to notify"`
+	Since   string             `json:"Since" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Day     string             `json:"Day" validate:"omitempty,datetime=2006-01-02"`
+	Clock   string             `json:"Clock" validate:"omitempty,datetime=15:04"`
+	Ignored string             `json:"-"`
+}
+
+type testSchemaController struct {
+	testController
+}
+
+func (controller *testSchemaController) GetDescription() ControllerDescription {
+	return &testSchemaDescription{}
+}
+
+func (controller *testSchemaController) GetResult() ControllerResult {
+	return &testResponse{}
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	generator := NewSchemaGenerator(openAPISchemaRef)
+	schema := generator.Generate(&testSchemaDescription{})
+	if schema.Ref != openAPISchemaRef+"testSchemaDescription" {
+		t.Fatalf("$ref = %q", schema.Ref)
+	}
+
+	description := generator.Schemas["testSchemaDescription"]
+	if !reflect.DeepEqual(description.Required, []string{"Email"}) {
+		t.Errorf("required = %v", description.Required)
+	}
+	if _, ok := description.Properties["Ignored"]; ok {
+		t.Error("field with `json:\"-\"` should be ignored")
+	}
+	if _, ok := description.Properties["Action"]; !ok {
+		t.Error("fields of embedded BaseDescription should be promoted")
+	}
+	if email := description.Properties["Email"]; email.Format != "email" || email.Description != "email to notify" {
+		t.Errorf("Email = %+v", email)
+	}
+	for name, want := range map[string]string{"Since": "date-time", "Day": "date", "Clock": ""} {
+		if format := description.Properties[name].Format; format != want {
+			t.Errorf("format of %s = %q, want %q", name, format, want)
+		}
+	}
+	if offset := description.Properties["Offset"]; offset.Type != jsonTypeInteger || *offset.Minimum != 0 {
+		t.Errorf("Offset = %+v", offset)
+	}
+
+	filters := description.Properties["Filters"]
+	if filters.Type != jsonTypeArray || filters.Items.Ref != openAPISchemaRef+"testSchemaFilter" {
+		t.Fatalf("Filters = %+v", filters)
+	}
+	filter := generator.Schemas["testSchemaFilter"]
+	if !reflect.DeepEqual(filter.Required, []string{"Name"}) {
+		t.Errorf("required = %v", filter.Required)
+	}
+	if enum := filter.Properties["Name"].Enum; !reflect.DeepEqual(enum, []interface{}{"zone", "region"}) {
+		t.Errorf("enum = %v", enum)
+	}
+	values := filter.Properties["Values"]
+	if *values.MinItems != 1 || *values.MaxItems != 5 || *values.Items.MaxLength != 60 {
+		t.Errorf("Values = %+v", values)
+	}
+}
+
+func TestServer_OpenAPI(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("DescribeTest", func() Controller { return &testSchemaController{} },
+		ActionMeta{Description: "describe test", Deprecated: true})
+	s := NewServer(NewRouter(registry), ServerOption{})
+
+	data, err := s.APIDocument("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	item, ok := doc.Paths["/DescribeTest"]
+	if !ok || item.Post == nil {
+		t.Fatalf("paths = %v", doc.Paths)
+	}
+	if item.Post.Summary != "describe test" || !item.Post.Deprecated {
+		t.Errorf("operation = %+v", item.Post)
+	}
+	for _, name := range []string{"testSchemaDescription", "testSchemaFilter", "testResponse", openAPIErrorSchema} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+
+	if _, err := NewServer(NewRouter(testFactory{}), ServerOption{}).APIDocument(""); err == nil {
+		t.Error("factory which can't list actions should fail")
+	}
+}
@@ -1,11 +1,13 @@
 package framework
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/SongOf/edge-storage-core/core/eserrors"
 	"github.com/mitchellh/mapstructure"
+	"io"
 	"regexp"
 	"strings"
 )
@@ -15,6 +17,9 @@ type Parser struct {
 	decodeFuncErrorRegexp  *regexp.Regexp
 	decodeSliceErrorRegexp *regexp.Regexp
 	decodeMapErrorRegexp   *regexp.Regexp
+
+	// decoders decode request body by content type
+	decoders map[string]RequestDecoder
 }
 
 //NewParser Create a parser TestObject
@@ -41,25 +46,28 @@ func NewParser() (*Parser, error) {
 		decodeFuncErrorRegexp:  compiledDecodeFuncErrorRegexp,
 		decodeSliceErrorRegexp: compiledDecodeSliceFuncErrorRegexp,
 		decodeMapErrorRegexp:   compiledDecodeMapErrorRegexp,
+		decoders:               defaultDecoders(),
 	}, nil
 }
 
 //PreParseRequest decode raw json to a map
 func (parser *Parser) PreParseRequest(rawJsonBody []byte) (map[string]interface{}, error) {
 	params := make(map[string]interface{})
-	if err := json.Unmarshal(rawJsonBody, &params); err != nil {
-		switch err.(type) {
-		case *json.SyntaxError:
-			return nil, eserrors.InvalidParameterEx(eserrors.InvalidParameterSyntaxCode, nil)
-		default:
-			return nil, fmt.Errorf("unmarshal json error:%v", err)
-		}
+	decoder := json.NewDecoder(bytes.NewReader(rawJsonBody))
+	if err := decoder.Decode(&params); err != nil {
+		return nil, jsonSyntaxError(err)
+	}
+	// only one json object is allowed in body
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, jsonSyntaxError(err)
 	}
-
 	return params, nil
 }
 
 //CheckParams check unused parameters, if exists, return an UnknownParameter
+//
+// Deprecated: use Bind, which decodes json body into description by a streaming decoder
+// and reports type errors with field path.
 func (parser *Parser) CheckParams(ctx context.Context, params map[string]interface{},
 	description ControllerDescription) error {
 
@@ -0,0 +1,389 @@
+package framework
+
+import (
+	"context"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/SongOf/edge-storage-core/storage/cache"
+	"github.com/go-redis/redis/v8"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitDimensionAppId  = "appid"
+	RateLimitDimensionIp     = "ip"
+	RateLimitDimensionTenant = "tenant"
+
+	rateLimitKeyPrefix = "ratelimit:"
+	// rateLimitTakenKey is the key of buckets taken by the request in ctx.Keys
+	rateLimitTakenKey = "RateLimitTaken"
+)
+
+// RateLimit is a token bucket, which is refilled by Rate tokens per second and holds at most Burst tokens.
+// Zero Rate means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (limit RateLimit) unlimited() bool {
+	return limit.Rate <= 0
+}
+
+// burst return capacity of bucket, which is at least 1
+func (limit RateLimit) burst() int {
+	if limit.Burst < 1 {
+		return 1
+	}
+	return limit.Burst
+}
+
+// RateLimitRule is limits of an action for each AppId and each source IP
+type RateLimitRule struct {
+	PerAppId RateLimit
+	PerIp    RateLimit
+}
+
+// RateLimiter is the backend of token buckets
+type RateLimiter interface {
+	// Allow take a token from bucket of key, and report whether there was a token
+	Allow(ctx context.Context, key string, limit RateLimit) (bool, error)
+}
+
+// Throttler is a middleware which limits requests, Throttle of middlewares in ServerOption.Middlewares
+// is called before request body is read, so that limited requests cost no reading and decoding.
+// Only headers of the request are available, and ctx.Action is set if action is routed by path or header
+type Throttler interface {
+	core.Middleware
+	Throttle(ctx *core.Context) error
+}
+
+type RateLimitOption struct {
+	// Limiter is the backend of buckets, default is NewMemoryRateLimiter()
+	Limiter RateLimiter
+	// Default is the rule of actions not in Actions
+	Default RateLimitRule
+	// Actions overrides Default for specific actions
+	Actions map[string]RateLimitRule
+	// Tenant is the limit of all actions for each AppId, in addition to limits of each action
+	Tenant RateLimit
+	// SecretProvider resolves AppId of SecretId in Authorization header, so that AppId limits are applied
+	// before request body is read. The signature isn't verified yet, nil means AppId limits are applied
+	// after the request is authenticated
+	SecretProvider SecretProvider
+	// IpHeader is the header of client IP set by trusted proxy, like `X-Real-IP` or `X-Forwarded-For`,
+	// empty means remote address
+	IpHeader string
+	// TrustedHops is the number of trusted proxies appending to IpHeader, default is 1.
+	// Client IP is the address appended by the farthest trusted proxy, addresses on its left are sent by client.
+	TrustedHops int
+	// Collector counts rejected requests, like Server.Collector()
+	Collector *ServerCollector
+}
+
+type rateLimitMiddleware struct {
+	option RateLimitOption
+}
+
+// NewRateLimitMiddleware create middleware which limits requests of each action by AppId and source IP,
+// and requests of each AppId by RateLimitOption.Tenant. AppId limits are not applied to requests without AppId.
+// Requests are allowed if the limiter fails.
+// It's a Throttler, limits are applied before request body is read if AppId and action are known by then
+func NewRateLimitMiddleware(option RateLimitOption) core.Middleware {
+	if option.Limiter == nil {
+		option.Limiter = NewMemoryRateLimiter()
+	}
+	return &rateLimitMiddleware{option: option}
+}
+
+// Throttle apply limits with headers of the request, Server calls it before request body is read.
+// AppId is resolved by RateLimitOption.SecretProvider, and limits of action are applied
+// if action is routed by path or header. The others are applied by Run
+func (middleware *rateLimitMiddleware) Throttle(ctx *core.Context) error {
+	appId := 0
+	if middleware.option.SecretProvider != nil {
+		appId = middleware.headerAppId(ctx)
+	}
+	return middleware.limit(ctx, appId)
+}
+
+func (middleware *rateLimitMiddleware) Run(ctx *core.Context) error {
+	if err := middleware.limit(ctx, ctx.UserInfo.AppId); err != nil {
+		return err
+	}
+	return ctx.Next()
+}
+
+// limit take a token from each bucket of the request, buckets taken by Throttle are skipped
+func (middleware *rateLimitMiddleware) limit(ctx *core.Context, appId int) error {
+	value, _ := ctx.Get(rateLimitTakenKey)
+	taken, ok := value.(map[string]bool)
+	if !ok {
+		taken = make(map[string]bool)
+		ctx.Set(rateLimitTakenKey, taken)
+	}
+	for _, bucket := range middleware.buckets(ctx, appId) {
+		if taken[bucket.key] {
+			continue
+		}
+		taken[bucket.key] = true
+		if !middleware.allow(ctx, bucket.key, bucket.limit) {
+			return middleware.reject(ctx, bucket.dimension, bucket.err())
+		}
+	}
+	return nil
+}
+
+type rateLimitBucket struct {
+	key       string
+	limit     RateLimit
+	dimension string
+	err       func() error
+}
+
+// buckets return limited buckets of the request, AppId buckets are skipped if appId is 0,
+// and buckets of action are skipped if action is unknown
+func (middleware *rateLimitMiddleware) buckets(ctx *core.Context, appId int) []rateLimitBucket {
+	buckets := make([]rateLimitBucket, 0, 3)
+	if tenant := middleware.option.Tenant; appId != 0 && !tenant.unlimited() {
+		buckets = append(buckets, rateLimitBucket{
+			key:       rateLimitKeyPrefix + RateLimitDimensionTenant + ":" + strconv.Itoa(appId),
+			limit:     tenant,
+			dimension: RateLimitDimensionTenant,
+			err: func() error {
+				return eserrors.RequestLimitExceededEx(eserrors.RequestLimitExceededTenantCode,
+					map[string]interface{}{"appId": appId, "rate": tenant.Rate})
+			},
+		})
+	}
+	if ctx.Action == "" {
+		return buckets
+	}
+
+	action := ctx.Action
+	rule, ok := middleware.option.Actions[action]
+	if !ok {
+		rule = middleware.option.Default
+	}
+	if appId != 0 && !rule.PerAppId.unlimited() {
+		buckets = append(buckets, rateLimitBucket{
+			key:       rateLimitKeyPrefix + action + ":" + RateLimitDimensionAppId + ":" + strconv.Itoa(appId),
+			limit:     rule.PerAppId,
+			dimension: RateLimitDimensionAppId,
+			err: func() error {
+				return eserrors.RequestLimitExceededEx(eserrors.RequestLimitExceededAppIdCode,
+					map[string]interface{}{"appId": appId, "action": action, "rate": rule.PerAppId.Rate})
+			},
+		})
+	}
+	if ip := middleware.sourceIp(ctx); ip != "" && !rule.PerIp.unlimited() {
+		buckets = append(buckets, rateLimitBucket{
+			key:       rateLimitKeyPrefix + action + ":" + RateLimitDimensionIp + ":" + ip,
+			limit:     rule.PerIp,
+			dimension: RateLimitDimensionIp,
+			err: func() error {
+				return eserrors.RequestLimitExceededEx(eserrors.RequestLimitExceededIpCode,
+					map[string]interface{}{"ip": ip, "action": action, "rate": rule.PerIp.Rate})
+			},
+		})
+	}
+	return buckets
+}
+
+// headerAppId return AppId of SecretId in Authorization header, it's 0 if SecretId is missing or not found
+func (middleware *rateLimitMiddleware) headerAppId(ctx *core.Context) int {
+	if ctx.Request == nil {
+		return 0
+	}
+	value := ctx.Request.Header.Get(AuthorizationHeader)
+	if value == "" {
+		return 0
+	}
+	authorization, err := parseAuthorization(value)
+	if err != nil {
+		return 0
+	}
+	secret, err := middleware.option.SecretProvider.GetSecret(ctx, authorization.secretId)
+	if err != nil {
+		eslog.C(ctx).Error("get secret failed", eslog.Field("SecretId", authorization.secretId), eslog.Err(err))
+		return 0
+	}
+	if secret == nil {
+		return 0
+	}
+	return secret.AppId
+}
+
+func (middleware *rateLimitMiddleware) allow(ctx *core.Context, key string, limit RateLimit) bool {
+	allowed, err := middleware.option.Limiter.Allow(ctx, key, limit)
+	if err != nil {
+		eslog.C(ctx).Error("rate limiter failed, allow request", eslog.Field("Key", key), eslog.Err(err))
+		return true
+	}
+	return allowed
+}
+
+func (middleware *rateLimitMiddleware) reject(ctx *core.Context, dimension string, err error) error {
+	eslog.C(ctx).Warn("request rate limited", eslog.Field("Action", ctx.Action), eslog.Field("Dimension", dimension))
+	if collector := middleware.option.Collector; collector != nil {
+		collector.RateLimitedCounterVector.WithLabelValues(ctx.Action, dimension).Inc()
+	}
+	ctx.Error = err
+	return err
+}
+
+func (middleware *rateLimitMiddleware) sourceIp(ctx *core.Context) string {
+	if ctx.Request == nil {
+		return ""
+	}
+	return clientIp(ctx.Request, middleware.option.IpHeader, middleware.option.TrustedHops)
+}
+
+// clientIp return IP of client read from header set by trusted proxies, see forwardedIp,
+// it's remote address of r if header is empty or missing
+func clientIp(r *http.Request, header string, hops int) string {
+	if header != "" {
+		if value := forwardedIp(r.Header.Values(header), hops); value != "" {
+			return value
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedIp return the address appended by the farthest of hops trusted proxies.
+// Proxies append to the right, so addresses on the left could be spoofed by client.
+func forwardedIp(values []string, hops int) string {
+	if hops < 1 {
+		hops = 1
+	}
+	addresses := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return ""
+	}
+	if hops > len(addresses) {
+		// request didn't pass all of the proxies, all addresses are appended by trusted ones
+		hops = len(addresses)
+	}
+	return addresses[len(addresses)-hops]
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time when bucket will be refilled to capacity
+	full time.Time
+}
+
+// take refill bucket since last taken and take a token
+func (bucket *tokenBucket) take(now time.Time, limit RateLimit) bool {
+	capacity := float64(limit.burst())
+	elapsed := now.Sub(bucket.last).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*limit.Rate)
+		bucket.last = now
+	}
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	bucket.full = now.Add(time.Duration((capacity - bucket.tokens) / limit.Rate * float64(time.Second)))
+	return allowed
+}
+
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextPurge time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimiter create RateLimiter in memory, limits are applied to each process separately
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+const memoryRateLimiterPurgeInterval = time.Minute
+
+func (limiter *memoryRateLimiter) Allow(_ context.Context, key string, limit RateLimit) (bool, error) {
+	now := limiter.now()
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if now.After(limiter.nextPurge) {
+		// full buckets are the same as new ones, drop them to bound memory
+		for k, bucket := range limiter.buckets {
+			if now.After(bucket.full) {
+				delete(limiter.buckets, k)
+			}
+		}
+		limiter.nextPurge = now.Add(memoryRateLimiterPurgeInterval)
+	}
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.burst()), last: now}
+		limiter.buckets[key] = bucket
+	}
+	return bucket.take(now, limit), nil
+}
+
+// tokenBucketScript is the same as tokenBucket.take, bucket is a hash of tokens and last time in milliseconds
+var tokenBucketScript = redis.NewScript(`
+local rate, capacity, now = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens, last = tonumber(bucket[1]), tonumber(bucket[2])
+if tokens == nil then
+	tokens, last = capacity, now
+end
+if now > last then
+	tokens = math.min(capacity, tokens + (now - last) / 1000 * rate)
+	last = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(last))
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return allowed
+`)
+
+type redisRateLimiter struct {
+	client redis.Cmdable
+}
+
+// NewRedisRateLimiter create RateLimiter on redis, limits are shared by all processes using the same redis
+func NewRedisRateLimiter(redisCache *cache.RedisCache) RateLimiter {
+	return &redisRateLimiter{client: redisCache.GetClient()}
+}
+
+func (limiter *redisRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (bool, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	allowed, err := tokenBucketScript.Run(ctx, limiter.client, []string{key},
+		limit.Rate, limit.burst(), now).Int()
+	if err != nil {
+		storage.CacheErrorInc()
+		return false, err
+	}
+	return allowed == 1, nil
+}
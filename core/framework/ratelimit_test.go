@@ -0,0 +1,244 @@
+package framework
+
+import (
+	"context"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	limiter := NewMemoryRateLimiter().(*memoryRateLimiter)
+	limiter.now = func() time.Time { return now }
+	limit := RateLimit{Rate: 2, Burst: 3}
+
+	allow := func() bool {
+		allowed, err := limiter.Allow(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allowed
+	}
+	for i := 0; i < 3; i++ {
+		if !allow() {
+			t.Fatalf("request %d within burst should be allowed", i)
+		}
+	}
+	if allow() {
+		t.Error("request exceeding burst should be rejected")
+	}
+
+	// 2 tokens per second
+	now = now.Add(500 * time.Millisecond)
+	if !allow() || allow() {
+		t.Error("one token should be refilled in 500ms")
+	}
+
+	// bucket refilled is purged
+	now = now.Add(memoryRateLimiterPurgeInterval + time.Second)
+	allow()
+	if _, ok := limiter.buckets["key"]; !ok || len(limiter.buckets) != 1 {
+		t.Errorf("unexpected buckets %v", limiter.buckets)
+	}
+}
+
+type testAppIdMiddleware int
+
+func (appId testAppIdMiddleware) Run(ctx *core.Context) error {
+	ctx.UserInfo.AppId = int(appId)
+	return ctx.Next()
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller { return &testController{} },
+		"CreateTest":   func() Controller { return &testController{} },
+	}, ServerOption{})
+	s.Option.Middlewares = []core.Middleware{
+		testAppIdMiddleware(1),
+		NewRateLimitMiddleware(RateLimitOption{
+			Default: RateLimitRule{PerIp: RateLimit{Rate: 0.001, Burst: 2}},
+			Actions: map[string]RateLimitRule{
+				"CreateTest": {PerAppId: RateLimit{Rate: 0.001, Burst: 1}},
+			},
+			Collector: s.Collector(),
+		}),
+	}
+
+	tests := []struct {
+		body     string
+		wantCode string
+	}{
+		{body: `{"Action":"DescribeTest","Name":"a","Limit":1}`},
+		{body: `{"Action":"DescribeTest","Name":"a","Limit":1}`},
+		{body: `{"Action":"DescribeTest","Name":"a","Limit":1}`, wantCode: "RequestLimitExceeded.IPLimitExceeded"},
+		{body: `{"Action":"CreateTest","Name":"a","Limit":1}`},
+		{body: `{"Action":"CreateTest","Name":"a","Limit":1}`, wantCode: "RequestLimitExceeded.AppIdLimitExceeded"},
+	}
+	for i, tt := range tests {
+		reply := doRequest(t, s.Handler(), tt.body)
+		if tt.wantCode == "" {
+			if reply.Response.Error != nil {
+				t.Fatalf("request %d: unexpected error %+v", i, *reply.Response.Error)
+			}
+			continue
+		}
+		if reply.Response.Error == nil || reply.Response.Error.Code != tt.wantCode {
+			t.Errorf("request %d: unexpected reply %+v, want code %s", i, reply.Response, tt.wantCode)
+		}
+	}
+
+	counter := s.Collector().RateLimitedCounterVector
+	if got := testutil.ToFloat64(counter.WithLabelValues("DescribeTest", RateLimitDimensionIp)); got != 1 {
+		t.Errorf("rate limited count of ip = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(counter.WithLabelValues("CreateTest", RateLimitDimensionAppId)); got != 1 {
+		t.Errorf("rate limited count of appid = %v, want 1", got)
+	}
+}
+
+func TestRateLimitMiddleware_Throttle(t *testing.T) {
+	const authorization = TC3Algorithm + " Credential=id-1/2021-09-01/es/tc3_request, " +
+		"SignedHeaders=content-type;host, Signature=unverified"
+
+	type request struct {
+		body     string
+		headers  []string
+		wantCode string
+	}
+	tests := []struct {
+		name     string
+		option   RateLimitOption
+		requests []request
+	}{
+		{
+			name: "tenant limit of AppId in Authorization header",
+			option: RateLimitOption{
+				Tenant:         RateLimit{Rate: 0.001, Burst: 2},
+				SecretProvider: StaticSecretProvider{"id-1": {AppId: 1}},
+			},
+			requests: []request{
+				{body: `not json`, headers: []string{AuthorizationHeader, authorization}, wantCode: "InvalidParameter.Syntax"},
+				{body: `not json`, headers: []string{AuthorizationHeader, authorization}, wantCode: "InvalidParameter.Syntax"},
+				{
+					body:     `not json`,
+					headers:  []string{AuthorizationHeader, authorization},
+					wantCode: "RequestLimitExceeded.TenantLimitExceeded",
+				},
+				{body: `not json`, wantCode: "InvalidParameter.Syntax"},
+			},
+		},
+		{
+			name:   "limit of action in header",
+			option: RateLimitOption{Default: RateLimitRule{PerIp: RateLimit{Rate: 0.001, Burst: 2}}},
+			requests: []request{
+				// bucket is taken once by Throttle and Run
+				{body: `{"Name":"a","Limit":1}`, headers: []string{DefaultActionHeader, "DescribeTest"}},
+				{body: `not json`, headers: []string{DefaultActionHeader, "DescribeTest"}, wantCode: "InvalidParameter.Syntax"},
+				{
+					body:     `not json`,
+					headers:  []string{DefaultActionHeader, "DescribeTest"},
+					wantCode: "RequestLimitExceeded.IPLimitExceeded",
+				},
+			},
+		},
+		{
+			name:   "limit of action in body",
+			option: RateLimitOption{Default: RateLimitRule{PerIp: RateLimit{Rate: 0.001, Burst: 1}}},
+			requests: []request{
+				{body: `not json`, wantCode: "InvalidParameter.Syntax"},
+				{body: `{"Action":"DescribeTest","Name":"a","Limit":1}`},
+				{body: `{"Action":"DescribeTest","Name":"a","Limit":1}`, wantCode: "RequestLimitExceeded.IPLimitExceeded"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(testFactory{
+				"DescribeTest": func() Controller { return &testController{} },
+			}, ServerOption{Middlewares: []core.Middleware{NewRateLimitMiddleware(tt.option)}})
+
+			for i, r := range tt.requests {
+				reply := doRequest(t, s.Handler(), r.body, r.headers...)
+				if r.wantCode == "" {
+					if reply.Response.Error != nil {
+						t.Fatalf("request %d: unexpected error %+v", i, *reply.Response.Error)
+					}
+					continue
+				}
+				if reply.Response.Error == nil || reply.Response.Error.Code != r.wantCode {
+					t.Errorf("request %d: unexpected reply %+v, want code %s", i, reply.Response, r.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware_sourceIp(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  RateLimitOption
+		headers []string
+		want    string
+	}{
+		{
+			name:    "remote address",
+			option:  RateLimitOption{},
+			headers: []string{"1.1.1.1"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "header of one proxy",
+			option:  RateLimitOption{IpHeader: "X-Real-IP"},
+			headers: []string{"1.1.1.1"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "spoofed prefix",
+			option:  RateLimitOption{IpHeader: "X-Forwarded-For"},
+			headers: []string{"6.6.6.6, 1.1.1.1"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "spoofed header line",
+			option:  RateLimitOption{IpHeader: "X-Forwarded-For"},
+			headers: []string{"6.6.6.6", "1.1.1.1"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "trusted hops",
+			option:  RateLimitOption{IpHeader: "X-Forwarded-For", TrustedHops: 2},
+			headers: []string{"6.6.6.6, 1.1.1.1, 10.0.0.2"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:    "fewer addresses than hops",
+			option:  RateLimitOption{IpHeader: "X-Forwarded-For", TrustedHops: 3},
+			headers: []string{"1.1.1.1, 10.0.0.2"},
+			want:    "1.1.1.1",
+		},
+		{
+			name:   "missing header",
+			option: RateLimitOption{IpHeader: "X-Forwarded-For"},
+			want:   "10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			request.RemoteAddr = "10.0.0.1:12345"
+			for _, header := range tt.headers {
+				request.Header.Add("X-Forwarded-For", header)
+				request.Header.Add("X-Real-IP", header)
+			}
+			middleware := NewRateLimitMiddleware(tt.option).(*rateLimitMiddleware)
+			if got := middleware.sourceIp(&core.Context{Request: request}); got != tt.want {
+				t.Errorf("sourceIp() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ActionMeta is metadata of an action, which is read by other framework features
+type ActionMeta struct {
+	Description string
+	// Permission is the permission required to call the action, like `cbs:DescribeVolumes`
+	Permission string
+	// Timeout overrides ServerOption.ActionTimeouts and ServerOption.RequestTimeout, zero means not set
+	Timeout time.Duration
+	// Deprecated actions are served with deprecation headers
+	Deprecated bool
+}
+
+// ActionMetaFactory is an optional interface of ControllerFactory, which provides ActionMeta
+type ActionMetaFactory interface {
+	ActionMeta(action string) (ActionMeta, bool)
+}
+
+type registryEntry struct {
+	newController func() Controller
+	meta          ActionMeta
+}
+
+// Registry is a ControllerFactory which creates controllers by registered constructors
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*registryEntry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]*registryEntry)}
+}
+
+// Register register constructor of controller of action with optional metadata,
+// it panics if action is registered twice, so duplicates are found at startup
+func (registry *Registry) Register(action string, newController func() Controller, meta ...ActionMeta) {
+	if err := registry.TryRegister(action, newController, meta...); err != nil {
+		panic(err)
+	}
+}
+
+// TryRegister is the same as Register, except that an error is returned instead of panic
+func (registry *Registry) TryRegister(action string, newController func() Controller, meta ...ActionMeta) error {
+	if action == "" {
+		return fmt.Errorf("register controller: empty action")
+	}
+	if newController == nil {
+		return fmt.Errorf("register controller: nil constructor of action `%s`", action)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.entries[action]; ok {
+		return fmt.Errorf("register controller: action `%s` is registered twice", action)
+	}
+	entry := &registryEntry{newController: newController}
+	if len(meta) > 0 {
+		entry.meta = meta[0]
+	}
+	registry.entries[action] = entry
+	return nil
+}
+
+func (registry *Registry) GetController(action string) Controller {
+	registry.mu.RLock()
+	entry, ok := registry.entries[action]
+	registry.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return entry.newController()
+}
+
+func (registry *Registry) ActionMeta(action string) (ActionMeta, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	entry, ok := registry.entries[action]
+	if !ok {
+		return ActionMeta{}, false
+	}
+	return entry.meta, true
+}
+
+// Actions return registered actions in ascending order
+func (registry *Registry) Actions() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	actions := make([]string, 0, len(registry.entries))
+	for action := range registry.entries {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
@@ -0,0 +1,64 @@
+package framework
+
+import (
+	"github.com/SongOf/edge-storage-core/core"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("DescribeTest", func() Controller { return &testController{} },
+		ActionMeta{Description: "describe test", Permission: "test:DescribeTest"})
+	registry.Register("CreateTest", func() Controller { return &testController{} })
+
+	if err := registry.TryRegister("DescribeTest", func() Controller { return &testController{} }); err == nil {
+		t.Error("duplicate registration should fail")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Register should panic on duplicate registration")
+			}
+		}()
+		registry.Register("CreateTest", func() Controller { return &testController{} })
+	}()
+
+	if actions := registry.Actions(); !reflect.DeepEqual(actions, []string{"CreateTest", "DescribeTest"}) {
+		t.Errorf("Actions() = %v", actions)
+	}
+	if registry.GetController("DescribeTest") == nil || registry.GetController("DeleteTest") != nil {
+		t.Error("unexpected GetController result")
+	}
+	if meta, ok := registry.ActionMeta("DescribeTest"); !ok || meta.Permission != "test:DescribeTest" {
+		t.Errorf("ActionMeta() = %+v, %v", meta, ok)
+	}
+}
+
+func TestServer_ActionMeta(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("DescribeTest", func() Controller {
+		return &testController{
+			entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		}
+	}, ActionMeta{Timeout: 20 * time.Millisecond, Deprecated: true})
+	s := NewServer(NewRouter(registry), ServerOption{RequestTimeout: time.Hour})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Action":"DescribeTest","Name":"a","Limit":1}`))
+	s.Handler().ServeHTTP(w, r)
+
+	if !strings.Contains(w.Body.String(), "RequestTimeout") {
+		t.Errorf("reply = %s, want RequestTimeout", w.Body.String())
+	}
+	if w.Header().Get(DeprecationHeader) != "true" {
+		t.Error("deprecated action should be served with Deprecation header")
+	}
+}
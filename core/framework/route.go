@@ -0,0 +1,144 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ParamAction    = "Action"
+	ParamVersion   = "Version"
+	ParamLanguage  = "Language"
+	ParamRequestId = "RequestId"
+
+	DefaultActionHeader    = "X-TC-Action"
+	DefaultVersionHeader   = "X-TC-Version"
+	DefaultLanguageHeader  = "X-TC-Language"
+	DefaultRequestIdHeader = "X-TC-RequestId"
+
+	// disabledHeader disables a header of RouteOption
+	disabledHeader = "-"
+)
+
+// RouteOption configures where Action, Version, Language and RequestId of a request come from.
+// Headers take precedence over URL path, and request body is the fallback.
+type RouteOption struct {
+	// ActionHeader defaults to X-TC-Action, `-` disables it
+	ActionHeader string
+	// VersionHeader defaults to X-TC-Version, `-` disables it
+	VersionHeader string
+	// LanguageHeader defaults to X-TC-Language, `-` disables it
+	LanguageHeader string
+	// RequestIdHeader defaults to X-TC-RequestId, `-` disables it
+	RequestIdHeader string
+	// PathPattern is a pattern like `/{Version}/{Action}` to capture values from URL path,
+	// empty means URL path is not used
+	PathPattern string
+}
+
+func (option *RouteOption) headers() map[string]string {
+	headers := map[string]string{
+		ParamAction:    DefaultActionHeader,
+		ParamVersion:   DefaultVersionHeader,
+		ParamLanguage:  DefaultLanguageHeader,
+		ParamRequestId: DefaultRequestIdHeader,
+	}
+	for param, header := range map[string]string{
+		ParamAction:    option.ActionHeader,
+		ParamVersion:   option.VersionHeader,
+		ParamLanguage:  option.LanguageHeader,
+		ParamRequestId: option.RequestIdHeader,
+	} {
+		switch header {
+		case "":
+		case disabledHeader:
+			delete(headers, param)
+		default:
+			headers[param] = header
+		}
+	}
+	return headers
+}
+
+// validate check PathPattern only captures known params
+func (option *RouteOption) validate() error {
+	for _, segment := range splitPath(option.PathPattern) {
+		if name, ok := pathParam(segment); ok {
+			switch name {
+			case ParamAction, ParamVersion, ParamLanguage, ParamRequestId:
+			default:
+				return fmt.Errorf("unknown param `%s` in path pattern `%s`", name, option.PathPattern)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve return Action, Version, Language and RequestId found in headers and path of r,
+// params not found are absent
+func (option *RouteOption) resolve(r *http.Request) map[string]string {
+	route := make(map[string]string)
+	for param, value := range option.matchPath(r.URL.Path) {
+		route[param] = value
+	}
+	for param, header := range option.headers() {
+		if value := r.Header.Get(header); value != "" {
+			route[param] = value
+		}
+	}
+	return route
+}
+
+// matchPath return params captured by PathPattern, nil if path doesn't match
+func (option *RouteOption) matchPath(path string) map[string]string {
+	if option.PathPattern == "" {
+		return nil
+	}
+	patterns, segments := splitPath(option.PathPattern), splitPath(path)
+	if len(patterns) != len(segments) {
+		return nil
+	}
+
+	captured := make(map[string]string)
+	for i, pattern := range patterns {
+		if name, ok := pathParam(pattern); ok {
+			captured[name] = segments[i]
+		} else if pattern != segments[i] {
+			return nil
+		}
+	}
+	return captured
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// pathParam return name of segment like `{Action}`
+func pathParam(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// routeValue return value of param resolved from headers and path, or from body params
+func routeValue(route map[string]string, params map[string]interface{}, param string) (interface{}, bool) {
+	if value, ok := route[param]; ok {
+		return value, true
+	}
+	value, ok := params[param]
+	return value, ok
+}
+
+// routeString is the same as routeValue, except that non-string value in body is ignored
+func routeString(route map[string]string, params map[string]interface{}, param string) string {
+	value, _ := routeValue(route, params, param)
+	s, _ := value.(string)
+	return s
+}
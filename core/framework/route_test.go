@@ -0,0 +1,110 @@
+package framework
+
+import (
+	"github.com/SongOf/edge-storage-core/core"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_Route(t *testing.T) {
+	factory := testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					return &testResponse{Name: ctx.Version + "/" + ctx.Language}, nil
+				},
+			}
+		},
+	}
+
+	tests := []struct {
+		name          string
+		option        RouteOption
+		target        string
+		headers       map[string]string
+		body          string
+		wantName      string
+		wantRequestId string
+		wantCode      string
+	}{
+		{
+			name:          "body",
+			body:          `{"Action":"DescribeTest","Version":"2021-09-01","Language":"en-US","RequestId":"req-1","Name":"a","Limit":1}`,
+			wantName:      "2021-09-01/en-US",
+			wantRequestId: "req-1",
+		},
+		{
+			name: "headers take precedence over body",
+			headers: map[string]string{
+				"X-TC-Action":    "DescribeTest",
+				"X-TC-Version":   "2022-03-01",
+				"X-TC-Language":  "zh-CN",
+				"X-TC-RequestId": "req-2",
+			},
+			body:          `{"Version":"2021-09-01","RequestId":"req-1","Name":"a","Limit":1}`,
+			wantName:      "2022-03-01/zh-CN",
+			wantRequestId: "req-2",
+		},
+		{
+			name:     "path",
+			option:   RouteOption{PathPattern: "/api/{Version}/{Action}"},
+			target:   "/api/2021-09-01/DescribeTest",
+			body:     `{"Name":"a","Limit":1}`,
+			wantName: "2021-09-01/",
+		},
+		{
+			name:     "custom header",
+			option:   RouteOption{ActionHeader: "X-Action", VersionHeader: "-"},
+			headers:  map[string]string{"X-Action": "DescribeTest", "X-TC-Version": "2022-03-01"},
+			body:     `{"Name":"a","Limit":1}`,
+			wantName: "/",
+		},
+		{
+			name:     "missing action",
+			option:   RouteOption{PathPattern: "/api/{Version}/{Action}"},
+			target:   "/api/DescribeTest",
+			body:     `{"Name":"a","Limit":1}`,
+			wantCode: "MissingParameter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(factory, ServerOption{RouteOption: tt.option})
+			target := tt.target
+			if target == "" {
+				target = "/"
+			}
+			r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.body))
+			for header, value := range tt.headers {
+				r.Header.Set(header, value)
+			}
+			reply := serveRequest(t, s.Handler(), r)
+
+			if tt.wantCode != "" {
+				if reply.Response.Error == nil || reply.Response.Error.Code != tt.wantCode {
+					t.Errorf("unexpected reply %+v, want code %s", reply.Response, tt.wantCode)
+				}
+				return
+			}
+			if reply.Response.Error != nil {
+				t.Fatalf("unexpected error %+v", *reply.Response.Error)
+			}
+			if reply.Response.Name != tt.wantName {
+				t.Errorf("version/language = %q, want %q", reply.Response.Name, tt.wantName)
+			}
+			if tt.wantRequestId != "" && reply.Response.RequestId != tt.wantRequestId {
+				t.Errorf("RequestId = %q, want %q", reply.Response.RequestId, tt.wantRequestId)
+			}
+		})
+	}
+}
+
+func TestRouteOption_Validate(t *testing.T) {
+	option := RouteOption{PathPattern: "/{Version}/{Region}/{Action}"}
+	if err := option.validate(); err == nil {
+		t.Error("unknown path param should be rejected")
+	}
+}
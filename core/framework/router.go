@@ -1,14 +1,127 @@
 package framework
 
-type Router struct {
+import (
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	DeprecationHeader = "Deprecation"
+	SunsetHeader      = "Sunset"
+	WarningHeader     = "Warning"
+)
+
+// VersionOption describes lifecycle of an API version
+type VersionOption struct {
+	// Deprecated marks version as deprecated, clients are warned by response headers
+	Deprecated bool
+	// Sunset is the time after which version will be removed, non-zero implies Deprecated
+	Sunset time.Time
+}
+
+func (option *VersionOption) deprecated() bool {
+	return option.Deprecated || !option.Sunset.IsZero()
+}
+
+type apiVersion struct {
 	factory ControllerFactory
+	option  VersionOption
+}
+
+type Router struct {
+	// factory serves requests without version, or all requests if no version is registered
+	factory  ControllerFactory
+	versions map[string]*apiVersion
 }
 
 func NewRouter(factory ControllerFactory) *Router {
-	r := &Router{factory: factory}
+	r := &Router{factory: factory, versions: make(map[string]*apiVersion)}
 	return r
 }
 
 func (r *Router) Dispatch(action string) Controller {
+	if r.factory == nil {
+		return nil
+	}
 	return r.factory.GetController(action)
 }
+
+// RegisterVersion register factory of controllers of version like `2021-09-01`,
+// factory registered before of the same version is replaced
+func (r *Router) RegisterVersion(version string, factory ControllerFactory, option VersionOption) {
+	r.versions[version] = &apiVersion{factory: factory, option: option}
+}
+
+// Versions return registered versions in ascending order
+func (r *Router) Versions() []string {
+	versions := make([]string, 0, len(r.versions))
+	for version := range r.versions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// DispatchVersion return controller of action in version.
+// Requests without version are dispatched by Dispatch, so are all requests if no version is registered.
+// UnsupportedVersion is returned if version is not registered.
+func (r *Router) DispatchVersion(version, action string) (Controller, error) {
+	factory, err := r.versionFactory(version)
+	if err != nil || factory == nil {
+		return nil, err
+	}
+	return factory.GetController(action), nil
+}
+
+// ActionMeta return metadata of action in version, if factory of the version implements ActionMetaFactory
+func (r *Router) ActionMeta(version, action string) (ActionMeta, bool) {
+	factory, _ := r.versionFactory(version)
+	metaFactory, ok := factory.(ActionMetaFactory)
+	if !ok {
+		return ActionMeta{}, false
+	}
+	return metaFactory.ActionMeta(action)
+}
+
+// versionFactory return factory serving version
+func (r *Router) versionFactory(version string) (ControllerFactory, error) {
+	if version == "" || len(r.versions) == 0 {
+		return r.factory, nil
+	}
+	v, ok := r.versions[version]
+	if !ok {
+		return nil, eserrors.UnsupportedVersion(version)
+	}
+	return v.factory, nil
+}
+
+// deprecationHeaders return headers to warn clients of deprecated version or action,
+// nil if neither is deprecated
+func (r *Router) deprecationHeaders(version, action string) http.Header {
+	if v, ok := r.versions[version]; ok && v.option.deprecated() {
+		return versionDeprecationHeaders(version, &v.option)
+	}
+	if meta, ok := r.ActionMeta(version, action); ok && meta.Deprecated {
+		header := make(http.Header)
+		header.Set(DeprecationHeader, "true")
+		header.Set(WarningHeader, fmt.Sprintf(`299 - "action %s is deprecated"`, action))
+		return header
+	}
+	return nil
+}
+
+func versionDeprecationHeaders(version string, option *VersionOption) http.Header {
+	header := make(http.Header)
+	header.Set(DeprecationHeader, "true")
+	warning := fmt.Sprintf("API version %s is deprecated", version)
+	if !option.Sunset.IsZero() {
+		sunset := option.Sunset.UTC().Format(http.TimeFormat)
+		header.Set(SunsetHeader, sunset)
+		warning += " and will be removed after " + sunset
+	}
+	header.Set(WarningHeader, fmt.Sprintf(`299 - "%s"`, warning))
+	return header
+}
@@ -0,0 +1,79 @@
+package framework
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Versions(t *testing.T) {
+	newFactory := func(name string) testFactory {
+		return testFactory{
+			"DescribeTest": func() Controller {
+				return &testController{description: testDescription{Name: name}}
+			},
+		}
+	}
+	router := NewRouter(newFactory("default"))
+	sunset := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	router.RegisterVersion("2021-09-01", newFactory("v1"), VersionOption{Sunset: sunset})
+	router.RegisterVersion("2022-03-01", newFactory("v2"), VersionOption{})
+	s := NewServer(router, ServerOption{})
+
+	tests := []struct {
+		name        string
+		version     string
+		wantName    string
+		wantCode    string
+		wantSunset  string
+		wantWarning bool
+	}{
+		{name: "without version", wantName: "default"},
+		{name: "current version", version: "2022-03-01", wantName: "v2"},
+		{
+			name:        "sunset version",
+			version:     "2021-09-01",
+			wantName:    "v1",
+			wantSunset:  "Thu, 01 Sep 2022 00:00:00 GMT",
+			wantWarning: true,
+		},
+		{name: "unknown version", version: "2020-01-01", wantCode: "UnsupportedVersion"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Action":"DescribeTest","Limit":1}`))
+			if tt.version != "" {
+				r.Header.Set(DefaultVersionHeader, tt.version)
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+			var reply testReply
+			if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+				t.Fatalf("unmarshal reply %q: %v", w.Body.String(), err)
+			}
+
+			if tt.wantCode != "" {
+				if reply.Response.Error == nil || reply.Response.Error.Code != tt.wantCode {
+					t.Errorf("unexpected reply %+v, want code %s", reply.Response, tt.wantCode)
+				}
+				return
+			}
+			if reply.Response.Error != nil {
+				t.Fatalf("unexpected error %+v", *reply.Response.Error)
+			}
+			if reply.Response.Name != tt.wantName {
+				t.Errorf("controller = %q, want %q", reply.Response.Name, tt.wantName)
+			}
+			if got := w.Header().Get(SunsetHeader); got != tt.wantSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.wantSunset)
+			}
+			if got := w.Header().Get(WarningHeader); (got != "") != tt.wantWarning {
+				t.Errorf("Warning = %q, want warning %v", got, tt.wantWarning)
+			}
+		})
+	}
+}
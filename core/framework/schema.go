@@ -0,0 +1,373 @@
+package framework
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchema is a JSON Schema (draft 2020-12) used by OpenAPI 3.1
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	UniqueItems          bool                   `json:"uniqueItems,omitempty"`
+	Deprecated           bool                   `json:"deprecated,omitempty"`
+}
+
+// formatOfTags are JSON Schema formats of validator tags
+var formatOfTags = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"hostname": "hostname",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaGenerator generate JSON Schema of go types from `json` and `validate` tags.
+// Named struct types are generated once into Schemas and referred by `$ref`.
+type SchemaGenerator struct {
+	// RefPrefix is prefix of `$ref`, like `#/components/schemas/`
+	RefPrefix string
+	Schemas   map[string]*JSONSchema
+
+	names map[reflect.Type]string
+}
+
+func NewSchemaGenerator(refPrefix string) *SchemaGenerator {
+	return &SchemaGenerator{
+		RefPrefix: refPrefix,
+		Schemas:   make(map[string]*JSONSchema),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// Generate return schema of type of v
+func (generator *SchemaGenerator) Generate(v interface{}) *JSONSchema {
+	return generator.schemaOf(reflect.TypeOf(v))
+}
+
+func (generator *SchemaGenerator) schemaOf(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &JSONSchema{Type: jsonTypeString, Format: "date-time"}
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		// custom json encoding, could be anything
+		return &JSONSchema{}
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return &JSONSchema{Type: jsonTypeString}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: jsonTypeString}
+	case reflect.Bool:
+		return &JSONSchema{Type: jsonTypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: jsonTypeInteger, Format: integerFormat(t)}
+	case reflect.Float32:
+		return &JSONSchema{Type: jsonTypeNumber, Format: "float"}
+	case reflect.Float64:
+		return &JSONSchema{Type: jsonTypeNumber, Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &JSONSchema{Type: jsonTypeString, Format: "byte"}
+		}
+		return &JSONSchema{Type: jsonTypeArray, Items: generator.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: jsonTypeObject, AdditionalProperties: generator.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return generator.structSchema(t)
+	default:
+		// interface{}
+		return &JSONSchema{}
+	}
+}
+
+func integerFormat(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int64, reflect.Uint64, reflect.Int, reflect.Uint:
+		return "int64"
+	default:
+		return "int32"
+	}
+}
+
+// structSchema return `$ref` to schema of named struct, anonymous struct is inlined
+func (generator *SchemaGenerator) structSchema(t reflect.Type) *JSONSchema {
+	if t.Name() == "" {
+		return generator.objectSchema(t)
+	}
+
+	name, ok := generator.names[t]
+	if !ok {
+		name = generator.uniqueName(t)
+		generator.names[t] = name
+		// register name before generating, so that recursive types refer to it
+		generator.Schemas[name] = nil
+		generator.Schemas[name] = generator.objectSchema(t)
+	}
+	return &JSONSchema{Ref: generator.RefPrefix + name}
+}
+
+// uniqueName return name of t, which is qualified by package if name is taken by another type
+func (generator *SchemaGenerator) uniqueName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := generator.Schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	name = strings.ReplaceAll(pkg[strings.LastIndex(pkg, "/")+1:], ".", "_") + "." + t.Name()
+	for i := 2; ; i++ {
+		if _, taken := generator.Schemas[name]; !taken {
+			return name
+		}
+		name = t.Name() + strconv.Itoa(i)
+	}
+}
+
+// objectSchema generate schema of struct fields in the same way as binding request
+func (generator *SchemaGenerator) objectSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{
+		Type:                 jsonTypeObject,
+		Properties:           make(map[string]*JSONSchema),
+		AdditionalProperties: false,
+	}
+
+	fields := make([]*bindField, 0)
+	for _, field := range cachedBindFields(t).byName {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return lessIndex(fields[i].index, fields[j].index)
+	})
+
+	for _, field := range fields {
+		sf := t.FieldByIndex(field.index)
+		property := generator.schemaOf(sf.Type)
+		if applyValidateTag(property, sf.Type, sf.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, field.name)
+		}
+		if description := sf.Tag.Get("description"); description != "" {
+			property = withDescription(property, description)
+		}
+		schema.Properties[field.name] = property
+	}
+	return schema
+}
+
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// withDescription add description to schema, `$ref` is wrapped since its siblings are ignored by some tools
+func withDescription(schema *JSONSchema, description string) *JSONSchema {
+	if schema.Ref != "" {
+		return &JSONSchema{OneOf: []*JSONSchema{schema}, Description: description}
+	}
+	schema.Description = description
+	return schema
+}
+
+// applyValidateTag apply constraints of validator tag to schema of type t, and report whether it's required.
+// Rules after `dive` are applied to items of array or values of map.
+func applyValidateTag(schema *JSONSchema, t reflect.Type, tag string) (required bool) {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	rules, inKeys := strings.Split(tag, ","), false
+	for i, rule := range rules {
+		// rules of map keys are not described
+		if rule == "keys" || rule == "endkeys" {
+			inKeys = rule == "keys"
+			continue
+		}
+		if inKeys {
+			continue
+		}
+		if rule == "dive" {
+			var elem *JSONSchema
+			switch {
+			case schema.Items != nil:
+				elem = schema.Items
+			case schema.AdditionalProperties != nil:
+				elem, _ = schema.AdditionalProperties.(*JSONSchema)
+			}
+			if elem != nil && elem.Ref == "" {
+				applyValidateTag(elem, t.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			break
+		}
+		if strings.Contains(rule, "|") {
+			// alternatives can't be described by simple constraints
+			continue
+		}
+
+		name, param := rule, ""
+		if index := strings.Index(rule, "="); index >= 0 {
+			name, param = rule[:index], rule[index+1:]
+		}
+		switch name {
+		case "required":
+			required = true
+		case "min", "gte":
+			setBound(schema, t, param, false)
+		case "max", "lte":
+			setBound(schema, t, param, true)
+		case "gt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil && isNumber(t) {
+				schema.ExclusiveMinimum = &n
+			}
+		case "lt":
+			if n, err := strconv.ParseFloat(param, 64); err == nil && isNumber(t) {
+				schema.ExclusiveMaximum = &n
+			}
+		case "len":
+			setBound(schema, t, param, false)
+			setBound(schema, t, param, true)
+		case "oneof":
+			schema.Enum = enumValues(t, param)
+		case "unique":
+			schema.UniqueItems = true
+		case "datetime":
+			setDatetimeFormat(schema, param)
+		default:
+			if format, ok := formatOfTags[name]; ok && schema.Format == "" {
+				schema.Format = format
+			}
+		}
+	}
+	return required
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setBound set lower or upper bound of number, length of string, or size of array
+func setBound(schema *JSONSchema, t reflect.Type, param string, upper bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	if isNumber(t) {
+		if upper {
+			schema.Maximum = &n
+		} else {
+			schema.Minimum = &n
+		}
+		return
+	}
+	size := int(n)
+	switch t.Kind() {
+	case reflect.String:
+		if upper {
+			schema.MaxLength = &size
+		} else {
+			schema.MinLength = &size
+		}
+	case reflect.Slice, reflect.Array:
+		if upper {
+			schema.MaxItems = &size
+		} else {
+			schema.MinItems = &size
+		}
+	}
+}
+
+// enumValues parse values of oneof like `a b 'c d'` as values of type t
+func enumValues(t reflect.Type, param string) []interface{} {
+	values := make([]interface{}, 0)
+	for _, value := range splitOneOf(param) {
+		if isNumber(t) {
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				values = append(values, json.Number(strconv.FormatFloat(n, 'f', -1, 64)))
+				continue
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// splitOneOf split param of oneof by space, values with space are quoted by `'`
+func splitOneOf(param string) []string {
+	values := make([]string, 0)
+	for param = strings.TrimSpace(param); param != ""; param = strings.TrimSpace(param) {
+		if param[0] == '\'' {
+			if end := strings.IndexByte(param[1:], '\''); end >= 0 {
+				values = append(values, param[1:end+1])
+				param = param[end+2:]
+				continue
+			}
+		}
+		end := strings.IndexByte(param, ' ')
+		if end < 0 {
+			end = len(param)
+		}
+		values = append(values, param[:end])
+		param = param[end:]
+	}
+	return values
+}
+
+// setDatetimeFormat set format of datetime tag with layout, layouts without JSON Schema format
+// are described instead
+func setDatetimeFormat(schema *JSONSchema, layout string) {
+	switch layout {
+	case time.RFC3339, time.RFC3339Nano:
+		schema.Format = "date-time"
+	case "2006-01-02":
+		schema.Format = "date"
+	default:
+		if schema.Description == "" {
+			schema.Description = "Time in layout `" + layout + "` of Go."
+		}
+	}
+}
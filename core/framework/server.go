@@ -15,27 +15,84 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/propagation"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
-	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
 const ConnKey = "http-conn"
+
+const (
+	// ControllerKey is the key of Controller of the request in ctx.Keys
+	ControllerKey = "Controller"
+	// ActionMetaKey is the key of ActionMeta of the request in ctx.Keys, it's set only if the action has metadata
+	ActionMetaKey = "ActionMeta"
+	// ResponseKey is the key of *ServerResponse of the request in ctx.Keys
+	ResponseKey = "ServerResponse"
+)
+
 const DefaultMaxBodySize = 10 * 1024 * 1024
 
+// ReportAllErrorsHeader overrides ServerOption.ReportAllErrors of a request, its value is `true` or `false`
+const ReportAllErrorsHeader = "X-Report-All-Errors"
+
 type ServerResponse struct {
 	Content    interface{} `json:"Response"`
 	ctx        *core.Context
 	writer     http.ResponseWriter
 	translator *i18n.Translator
+	sealer     *responseSealer
+}
+
+// responseSealer make sure that at most one response is written for a request,
+// and nothing is written after the http handler returned
+type responseSealer struct {
+	mu     sync.Mutex
+	sealed bool
+	// code and size of the written response
+	code string
+	size int
+}
+
+// seal forbid any later write of the response
+func (sealer *responseSealer) seal() {
+	sealer.mu.Lock()
+	sealer.sealed = true
+	sealer.mu.Unlock()
+}
+
+func (sealer *responseSealer) isSealed() bool {
+	sealer.mu.Lock()
+	defer sealer.mu.Unlock()
+	return sealer.sealed
+}
+
+// replied return code and size of the written response, code is empty if nothing is written
+func (sealer *responseSealer) replied() (code string, size int) {
+	sealer.mu.Lock()
+	defer sealer.mu.Unlock()
+	return sealer.code, sealer.size
+}
+
+func newServerResponse(ctx *core.Context, w http.ResponseWriter, translator *i18n.Translator) *ServerResponse {
+	return &ServerResponse{ctx: ctx, writer: w, translator: translator, sealer: &responseSealer{}}
+}
+
+// derive create a new ServerResponse which shares the writer with sr
+func (sr *ServerResponse) derive() *ServerResponse {
+	return &ServerResponse{ctx: sr.ctx, writer: sr.writer, translator: sr.translator, sealer: sr.sealer}
 }
 
 type ErrorCode struct {
@@ -43,9 +100,16 @@ type ErrorCode struct {
 	Message string `json:"Message"`
 }
 
+type FieldErrorCode struct {
+	Code    string `json:"Code"`
+	Message string `json:"Message"`
+	Field   string `json:"Field"`
+}
+
 type ErrorCodeWithRequestId struct {
-	Error     ErrorCode `json:"Error"`
-	RequestId string    `json:"RequestId"`
+	Error     ErrorCode        `json:"Error"`
+	Errors    []FieldErrorCode `json:"Errors,omitempty"`
+	RequestId string           `json:"RequestId"`
 }
 
 func (sr *ServerResponse) WithError(err error) *ServerResponse {
@@ -62,30 +126,41 @@ func (sr *ServerResponse) WithError(err error) *ServerResponse {
 		code, message = eserrors.InternalError().Format()
 	}
 
-	if sr.translator != nil {
-		if lang, ok := sr.ctx.Params["Language"]; ok {
-			language, ok := lang.(string)
-			if !ok {
-				eslog.C(sr.ctx).Warn("language is not string")
-				goto End
-			}
-			transMessage, err := sr.translator.Translate(language, code, message, data)
-			if err != nil {
-				eslog.C(sr.ctx).Warn("translate error", eslog.Field("Error", err))
-			} else {
-				message = transMessage
-			}
-		}
+	content := ErrorCodeWithRequestId{
+		Error:     ErrorCode{Code: code, Message: sr.translate(code, message, data)},
+		RequestId: sr.ctx.TraceId,
 	}
 
-End:
-	sr.Content = ErrorCodeWithRequestId{
-		Error:     ErrorCode{Code: code, Message: message},
-		RequestId: sr.ctx.TraceId,
+	// errors of all fields are reported, besides the first one
+	if multiErr, ok := err.(*eserrors.MultiError); ok {
+		for _, fieldErr := range multiErr.Errors() {
+			code, message := fieldErr.Format()
+			content.Errors = append(content.Errors, FieldErrorCode{
+				Code:    code,
+				Message: sr.translate(code, message, fieldErr.GetData()),
+				Field:   fieldErr.Field,
+			})
+		}
 	}
+
+	sr.Content = content
 	return sr
 }
 
+// translate message of code to the language of request,
+// message is returned if no translator or language
+func (sr *ServerResponse) translate(code, message string, data interface{}) string {
+	if sr.translator == nil || sr.ctx.Language == "" {
+		return message
+	}
+	transMessage, err := sr.translator.Translate(sr.ctx.Language, code, message, data)
+	if err != nil {
+		eslog.C(sr.ctx).Warn("translate error", eslog.Field("Error", err))
+		return message
+	}
+	return transMessage
+}
+
 func (sr *ServerResponse) WithResult(response ControllerResult) *ServerResponse {
 	requestId := sr.ctx.TraceId
 	response.WithRequestId(requestId)
@@ -95,6 +170,16 @@ func (sr *ServerResponse) WithResult(response ControllerResult) *ServerResponse
 
 func (sr *ServerResponse) Reply() {
 	body, _ := json.Marshal(sr)
+	if sr.sealer != nil {
+		sr.sealer.mu.Lock()
+		defer sr.sealer.mu.Unlock()
+		if sr.sealer.sealed {
+			eslog.C(sr.ctx).Warn("response has been sealed, drop reply", eslog.Field("Response", string(body)))
+			return
+		}
+		sr.sealer.sealed = true
+		sr.sealer.code, sr.sealer.size = sr.code(), len(body)
+	}
 	eslog.L().Info("server reply", eslog.Field("Response", string(body)))
 	_, err := fmt.Fprint(sr.writer, string(body))
 	if err != nil {
@@ -102,10 +187,21 @@ func (sr *ServerResponse) Reply() {
 	}
 }
 
+// code return error code of the response, or CodeSuccess
+func (sr *ServerResponse) code() string {
+	if content, ok := sr.Content.(ErrorCodeWithRequestId); ok {
+		return content.Error.Code
+	}
+	return CodeSuccess
+}
+
 type Server struct {
 	// http server
 	server *http.Server
 
+	// mux owned by Server, EntryList and default entrypoint are registered on it
+	mux *http.ServeMux
+
 	// framework.Server router
 	// dispatch request to Controller by Action name
 	router *Router
@@ -119,6 +215,23 @@ type Server struct {
 	// collector used to collect server metrics
 	collector *ServerCollector
 
+	// concurrency limits requests in process
+	concurrency *concurrencyLimiter
+
+	// listeners created by Start, and their listen addresses
+	listeners   []net.Listener
+	listenAddrs []string
+
+	// inflight tracks controllers running after request timeout
+	inflight sync.WaitGroup
+
+	// certReloader provides tls config with the latest certificates
+	certReloader *certReloader
+
+	// stopCh is closed when s is stopped
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
 	// framework server option
 	Option ServerOption
 }
@@ -129,11 +242,28 @@ type ServerOption struct {
 	PidFile         string
 	ShutdownTimeout int
 	TLSOption       TLSOption
+	GracefulOption  GracefulOption
 	MaxBodySize     int64
 	EntryList       []*Entry
 	Reporter        core.Reporter
 	TranslateDir    string
 	Middlewares     []core.Middleware
+
+	// ListenAddrs are additional listen addresses like `tcp://0.0.0.0:80` or `unix:///var/run/es.sock`
+	ListenAddrs []string
+	// RequestTimeout is the default timeout of every action, zero means no timeout
+	RequestTimeout time.Duration
+	// ActionTimeouts overrides RequestTimeout for specific actions
+	ActionTimeouts map[string]time.Duration
+	// ReportAllErrors reports all invalid parameters instead of the first one,
+	// it can be overridden by ReportAllErrorsHeader of request
+	ReportAllErrors bool
+	// RouteOption configures headers and URL path to resolve Action, Version, Language and RequestId
+	RouteOption RouteOption
+	// ConcurrencyOption limits requests in process of server and each action
+	ConcurrencyOption ConcurrencyOption
+	// CollectorOption configures namespace, labels and buckets of metrics of server
+	CollectorOption CollectorOption
 }
 
 type Entry struct {
@@ -141,11 +271,6 @@ type Entry struct {
 	Handler http.Handler
 }
 
-type TLSOption struct {
-	CertFile string
-	KeyFile  string
-}
-
 func SaveConnInContext(ctx context.Context, c net.Conn) context.Context {
 	return context.WithValue(ctx, ConnKey, c)
 }
@@ -179,53 +304,87 @@ func NewServer(router *Router, option ServerOption) *Server {
 		option.MaxBodySize = DefaultMaxBodySize
 	}
 
+	if err := option.RouteOption.validate(); err != nil {
+		eslog.L().Panic("invalid route option", eslog.Err(err))
+	}
+
+	collector := NewCollectorEx(option.CollectorOption)
 	s := &Server{
-		server:     &httpServer,
-		router:     router,
-		Option:     option,
-		parser:     parser,
-		validator:  newValidator,
-		collector:  NewCollector(),
-		translator: translator,
+		server:      &httpServer,
+		mux:         http.NewServeMux(),
+		router:      router,
+		Option:      option,
+		parser:      parser,
+		validator:   newValidator,
+		collector:   collector,
+		concurrency: newConcurrencyLimiter(option.ConcurrencyOption, collector),
+		translator:  translator,
+		stopCh:      make(chan struct{}),
+	}
+
+	for _, entry := range option.EntryList {
+		s.mux.Handle(entry.Path, entry.Handler)
+		eslog.L().Info("add handler", eslog.Field("Path", entry.Path))
 	}
+	s.mux.Handle("/", http.HandlerFunc(s.defaultEntrypoint))
+	httpServer.Handler = s.mux
 
 	return s
 }
 
+// Handler return the http.Handler serving all requests of s,
+// it can be used to run s by other http server or httptest without opening a socket
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start listen on all listen addresses and serve in background.
+// If graceful is true, listeners are inherited from parent process started Reload.
+// Start returns the first listener, all listeners are returned by Listeners().
+// TLS is only enabled on tcp listeners, unix socket is served in plain http.
 func (s *Server) Start(tls, graceful bool) (listener net.Listener, err error) {
-	for _, entry := range s.Option.EntryList {
-		http.Handle(entry.Path, entry.Handler)
-		eslog.L().Info("add handler", eslog.Field("Path", entry.Path))
+	addrs := s.Option.listenAddrs()
+	if len(addrs) == 0 {
+		return nil, errors.New("no listen address")
 	}
-	defaultHandler := http.HandlerFunc(s.defaultEntrypoint)
-	http.Handle("/", defaultHandler)
-	eslog.L().Info("Serving on " + s.Option.ListenAddr)
+	eslog.L().Info("Serving on " + strings.Join(addrs, ","))
 
+	if tls && s.certReloader == nil {
+		reloader, err := newCertReloader(&s.Option.TLSOption)
+		if err != nil {
+			eslog.L().Warn("load tls config error", eslog.Err(err))
+			return nil, err
+		}
+		s.certReloader = reloader
+		s.server.TLSConfig = reloader.TLSConfig()
+		if interval := s.Option.TLSOption.ReloadInterval; interval > 0 {
+			go reloader.watch(interval, s.stopCh)
+		}
+	}
+
+	var listeners []net.Listener
 	if graceful {
-		log.Print("main: Listening to existing file descriptor 3.")
-		f := os.NewFile(3, "")
-		ln, err := net.FileListener(f)
+		listenerFD := s.Option.GracefulOption.listenerFD()
+		log.Printf("main: Listening to existing file descriptor from %d.", listenerFD)
+		listeners, err = inheritListeners(listenerFD, addrs)
 		if err != nil {
 			eslog.L().Warn("listen error", eslog.Field("Error", err))
 			return nil, err
 		}
-		// Closing ln does not affect f, and closing f does not affect ln.
-		_ = f.Close()
-		listener = ln
-
-		// kill ppid
-		ppid := syscall.Getppid()
-		log.Printf("main: Killing parent pid: %v", ppid)
-		_ = syscall.Kill(ppid, syscall.SIGTERM)
 	} else {
 		log.Print("main: Listening on a new file descriptor.")
-		ln, err := net.Listen("tcp", s.server.Addr)
-		if err != nil {
-			eslog.L().Warn("listen error", eslog.Err(err))
-			return nil, err
+		for _, addr := range addrs {
+			ln, err := listen(addr)
+			if err != nil {
+				eslog.L().Warn("listen error", eslog.Err(err))
+				closeListeners(listeners...)
+				return nil, err
+			}
+			listeners = append(listeners, ln)
 		}
-		listener = ln
 	}
+	s.listeners, s.listenAddrs = listeners, addrs
+	listener = listeners[0]
 
 	// update pidfile
 	if s.Option.PidFile != "" {
@@ -238,95 +397,166 @@ func (s *Server) Start(tls, graceful bool) (listener net.Listener, err error) {
 		}
 	}
 
-	go func() {
-		if tls {
-			tlsOption := s.Option.TLSOption
-			err = s.server.ServeTLS(listener, tlsOption.CertFile, tlsOption.KeyFile)
-		} else {
-			err = s.server.Serve(listener)
-		}
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			eslog.L().Panic("serve error:", eslog.Err(err))
+	for _, ln := range listeners {
+		go s.serve(ln, tls)
+	}
+
+	if graceful {
+		// parent process hands off only after receiving ready message
+		if err = s.notifyReady(); err != nil {
+			eslog.L().Warn("notify parent ready failed", eslog.Err(err))
+			return listener, err
 		}
-	}()
-	// s.signalHandler(listener)
+	}
 	return
 }
 
-func (s *Server) Stop(timeout int) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
-	if err := s.server.Shutdown(ctx); err != nil {
-		eslog.L().Warn("force shutdown", eslog.Field("Error", err))
+// ReloadCertificates reload cert/key and client CA files of TLSOption,
+// new connections use the reloaded certificates
+func (s *Server) ReloadCertificates() error {
+	if s.certReloader == nil {
+		return errors.New("tls is not enabled")
 	}
+	return s.certReloader.Reload()
 }
 
-func (s *Server) Reload(listener net.Listener) error {
-	tl, ok := listener.(*net.TCPListener)
-	if !ok {
-		return errors.New("listener is not tcp listener")
+// Listeners return listeners created by Start
+func (s *Server) Listeners() []net.Listener {
+	return s.listeners
+}
+
+func (s *Server) serve(listener net.Listener, tls bool) {
+	var serveErr error
+	if _, isUnix := listener.(*net.UnixListener); tls && !isUnix {
+		// certificates are provided by s.server.TLSConfig
+		serveErr = s.server.ServeTLS(listener, "", "")
+	} else {
+		serveErr = s.server.Serve(listener)
 	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		eslog.L().Panic("serve error:", eslog.Err(serveErr))
+	}
+}
 
-	f, err := tl.File()
+// Run start s and block until ctx is done or a stop signal is received.
+// TLS is enabled when TLSOption.CertFile is set, and listeners are inherited from
+// parent process when GracefulOption.Flag is in command line arguments.
+// SIGINT/SIGTERM stop accepting new connections and drain in-flight requests.
+// SIGHUP reload tls certificates.
+// SIGUSR2 start a new process with the same listeners, current process stops after
+// the new process is ready, and keeps serving if the new process failed.
+// PidFile is removed before Run returns if it's still owned by current process.
+func (s *Server) Run(ctx context.Context) error {
+	// signals are handled once PidFile is written
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2, syscall.SIGHUP)
+	defer signal.Stop(ch)
+
+	tls := s.Option.TLSOption.CertFile != ""
+	_, err := s.Start(tls, s.isGracefulChild())
 	if err != nil {
 		return err
 	}
+	defer s.removePidFile()
 
-	args := []string{"-graceful"}
-	cmd := exec.Command(os.Args[0], args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	// put socket FD at the first entry
-	// cmd.ExtraFiles: If non-nil, entry i becomes file descriptor 3+i.
-	cmd.ExtraFiles = []*os.File{f}
-	return cmd.Start()
-}
-
-func (s *Server) signalHandler(listener net.Listener) {
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
+	timeout := s.Option.ShutdownTimeout
 	for {
-		sig := <-ch
-		println("receive signal %s", sig)
-		// mxlog.L().Info("receive signal", mxlog.Field("Signal", sig))
-		// timeout context for shutdown
-		timeout := s.Option.ShutdownTimeout
-		switch sig {
-		case syscall.SIGINT, syscall.SIGTERM:
-			// stop
-			eslog.L().Info("receive stop signal, will stop server")
-			signal.Stop(ch)
+		select {
+		case <-ctx.Done():
+			eslog.L().Info("context done, will stop server", eslog.Err(ctx.Err()))
 			s.Stop(timeout)
 			eslog.L().Info("graceful stop server")
-			return
-		case syscall.SIGUSR2:
-			// Reload
-			eslog.L().Info("receive Reload signal, will Reload server")
-			err := s.Reload(listener)
-			if err != nil {
-				eslog.L().Error("graceful restart error", eslog.Field("Error", err))
+			return nil
+		case sig := <-ch:
+			eslog.L().Info("receive signal", eslog.Field("Signal", sig.String()))
+			switch sig {
+			case syscall.SIGINT, syscall.SIGTERM:
+				eslog.L().Info("receive stop signal, will stop server")
+				s.Stop(timeout)
+				eslog.L().Info("graceful stop server")
+				return nil
+			case syscall.SIGUSR2:
+				eslog.L().Info("receive Reload signal, will Reload server")
+				if err := s.Reload(); err != nil {
+					eslog.L().Error("graceful restart error, keep serving", eslog.Err(err))
+					continue
+				}
+				s.Stop(timeout)
+				eslog.L().Info("graceful Reload server")
+				return nil
+			case syscall.SIGHUP:
+				if !tls {
+					continue
+				}
+				if err := s.ReloadCertificates(); err != nil {
+					eslog.L().Error("reload tls certificates failed", eslog.Err(err))
+				} else {
+					eslog.L().Info("tls certificates reloaded")
+				}
 			}
-			s.Stop(timeout)
-			eslog.L().Info("graceful Reload server")
-		default:
-			continue
 		}
 	}
 }
 
+// Stop shutdown http server gracefully, and wait for in-flight controllers
+// detached by request timeout, at most timeout seconds
+func (s *Server) Stop(timeout int) {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		eslog.L().Warn("force shutdown", eslog.Field("Error", err))
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		eslog.L().Warn("drain in-flight requests timeout")
+	}
+}
+
+// removePidFile remove PidFile if it's written by current process,
+// PidFile written by the reloaded process is kept
+func (s *Server) removePidFile() {
+	if s.Option.PidFile == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(s.Option.PidFile)
+	if err != nil {
+		eslog.L().Warn("read pidfile failed", eslog.Err(err))
+		return
+	}
+	if strings.TrimSpace(string(content)) != strconv.Itoa(syscall.Getpid()) {
+		return
+	}
+	if err := os.Remove(s.Option.PidFile); err != nil {
+		eslog.L().Warn("remove pidfile failed", eslog.Err(err))
+	}
+}
+
 func (s *Server) initContext(r *http.Request) *core.Context {
-	ctx := core.NewContext()
-	ctx.Request = r
+	// spans of request join the trace of traceparent header
+	r = r.WithContext(propagation.ExtractHTTP(r.Context(), global.Propagators(), r.Header))
+	ctx := core.NewRequestContext(r)
 	ctx.Reporter = s.Option.Reporter
 
 	return ctx
 }
 
-func (s *Server) initTraceId(ctx *core.Context) {
-	requestId := ""
-	if reqId, exist := ctx.Params["RequestId"]; exist {
-		requestId = reqId.(string)
-	} else {
+// initTraceId set RequestId of route or params, it's kept if neither has RequestId, or generated at first
+func (s *Server) initTraceId(ctx *core.Context, route map[string]string) {
+	requestId := routeString(route, ctx.Params, ParamRequestId)
+	if requestId == "" {
+		requestId = ctx.TraceId
+	}
+	if requestId == "" {
 		requestId = uuid.New().String()
 	}
 
@@ -338,14 +568,43 @@ func (s *Server) defaultEntrypoint(w http.ResponseWriter, r *http.Request) {
 	// parse -> dispatch -> validate -> process -> output
 
 	ctx := s.initContext(r)
-	resp := ServerResponse{ctx: ctx, writer: w, translator: s.translator}
-	defer recovery.Recover(ctx, func() {
-		if s.collector != nil {
-			// panic count
-			s.collector.ServerPanicCounter.Inc()
+	defer ctx.Cancel()
+	endRequest := ctx.StartSpan(requestSpanName, trace.WithSpanKind(trace.SpanKindServer))
+	defer func() { endRequest(ctx.Error) }()
+	route := s.Option.RouteOption.resolve(r)
+	// language in headers and path is used to translate errors before body is decoded
+	ctx.Language = route[ParamLanguage]
+	// requests replied before body is decoded have RequestId too
+	s.initTraceId(ctx, route)
+	resp := newServerResponse(ctx, w, s.translator)
+	observer := s.collector.observeRequest()
+	observer.requestSize = r.ContentLength
+	defer observer.finish(ctx, resp)
+	defer resp.sealer.seal()
+	defer recovery.Recover(ctx, s.panicHandler(resp))
+
+	endThrottle := ctx.StartSpan("Throttle")
+	err := s.throttle(ctx, route)
+	endThrottle(err)
+	if err != nil {
+		resp.WithError(err).Reply()
+		return
+	}
+
+	// shed load before request body is buffered
+	releaseGlobal, err := s.concurrency.acquireGlobal(ctx)
+	if err != nil {
+		eslog.C(ctx).Warn("request shed by global concurrency limit", eslog.Err(err))
+		resp.WithError(err).Reply()
+		return
+	}
+	// the slot is released by the goroutine of controller if it's detached by timeout
+	detached := false
+	defer func() {
+		if !detached {
+			releaseGlobal()
 		}
-		resp.WithError(eserrors.InternalError()).Reply()
-	})
+	}()
 
 	r.Body = http.MaxBytesReader(w, r.Body, s.Option.MaxBodySize)
 	body, err := ioutil.ReadAll(r.Body)
@@ -361,50 +620,73 @@ func (s *Server) defaultEntrypoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	eslog.L().Info("receive", eslog.Field("body", string(body)))
+	ctx.Body = body
+	observer.requestSize = int64(len(body))
 
-	params, parseError := s.parser.PreParseRequest(body)
+	endParse := ctx.StartSpan("Parse")
+	request, parseError := s.parser.DecodeRequest(r, body)
+	endParse(parseError)
 	if parseError != nil {
 		resp.WithError(parseError).Reply()
 		return
 	}
+	params := request.Params
 	ctx.Params = params
-	s.initTraceId(ctx)
-
-	value, ok := params["Action"]
-	if !ok {
-		resp.WithError(eserrors.MissingParameter("Action")).Reply()
-		return
-	}
-	action, ok := value.(string)
-	if !ok {
-		resp.WithError(eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueTypeCode,
-			map[string]interface{}{
-				"parameter":  "Action",
-				"actualType": reflect.TypeOf(value).Kind(),
-				"expectType": "string",
-			})).Reply()
+	ctx.Version = routeString(route, params, ParamVersion)
+	ctx.Language = routeString(route, params, ParamLanguage)
+	s.initTraceId(ctx, route)
+	ctx.Span().SetAttributes(label.String(RequestIdAttribute, ctx.TraceId))
+
+	endAuth := ctx.StartSpan("Authenticate")
+	err = s.authenticate(ctx)
+	endAuth(err)
+	if err != nil {
+		resp.WithError(err).Reply()
 		return
 	}
-	ctx.Action = action
 
-	actionController := s.router.Dispatch(action)
-	if actionController == nil {
-		resp.WithError(eserrors.InvalidAction(action)).Reply()
+	endDispatch := ctx.StartSpan("Dispatch")
+	actionController, err := s.dispatch(ctx, route)
+	endDispatch(err)
+	if err != nil {
+		resp.WithError(err).Reply()
 		return
 	}
+	action := ctx.Action
+	observer.action, observer.version = action, ctx.Version
+	ctx.Span().SetName(action)
+	ctx.Span().SetAttributes(label.String(ActionAttribute, action), label.String(VersionAttribute, ctx.Version))
+	for header, values := range s.router.deprecationHeaders(ctx.Version, action) {
+		w.Header()[header] = values
+	}
 
 	description := actionController.GetDescription()
 
+	reportAll := s.reportAllErrors(r)
+	validate := s.validator.ValidateParameters
+	if reportAll {
+		validate = s.validator.ValidateAllParameters
+	}
+
 	// 检查未使用字段和类型错误
-	if err := s.parser.CheckParams(ctx, params, description); err != nil {
+	endCheck := ctx.StartSpan("CheckParams")
+	err = s.parser.BindRequest(ctx, request, description, reportAll)
+	endCheck(err)
+	bindErrs, _ := err.(*eserrors.MultiError)
+	if err != nil && bindErrs == nil {
 		eslog.C(ctx).Warn("check params failed", eslog.Err(err))
 		resp.WithError(err).Reply()
 		return
 	}
 
-	//检查验证器
-	if err := s.validator.ValidateParameters(ctx, description); err != nil {
+	//检查验证器，所有错误都返回时，绑定错误和验证错误一起返回
+	endValidate := ctx.StartSpan("ValidateParameters")
+	err = validate(ctx, description)
+	endValidate(err)
+	if bindErrs != nil {
+		err = mergeFieldErrors(bindErrs, err)
+	}
+	if err != nil {
 		eslog.C(ctx).Warn("validate params failed", eslog.Err(err))
 		resp.WithError(err).Reply()
 		return
@@ -412,14 +694,195 @@ func (s *Server) defaultEntrypoint(w http.ResponseWriter, r *http.Request) {
 
 	eslog.C(ctx).Info("run controller", eslog.Field("Action", action), eslog.Field("RequestBody", params))
 
-	ctx.Use(NewLatencyMiddleware(s.collector))
-	ctx.Use(s.Option.Middlewares...)
-	ctx.Use(NewResultMiddleware(actionController, &resp, s.collector))
-	_ = ctx.Next()
+	releaseAction, err := s.concurrency.acquireAction(ctx, action)
+	if err != nil {
+		eslog.C(ctx).Warn("request shed by action concurrency limit", eslog.Field("Action", action), eslog.Err(err))
+		resp.WithError(err).Reply()
+		return
+	}
+
+	ctx.Set(ControllerKey, actionController)
+	ctx.Set(ResponseKey, resp)
+	if meta, ok := s.router.ActionMeta(ctx.Version, action); ok {
+		ctx.Set(ActionMetaKey, meta)
+	}
+	ctx.Use(traceMiddlewares(s.Option.Middlewares...)...)
+	ctx.Use(NewResultMiddleware(actionController, resp, s.collector))
+
+	timeout := s.actionTimeout(ctx.Version, action, actionController)
+	if timeout <= 0 {
+		defer releaseAction()
+		s.runMiddlewares(ctx, resp)
+		return
+	}
+
+	// run middlewares and controller in another goroutine,
+	// so that we can reply as soon as the deadline exceeded.
+	// The goroutine runs on a fork of ctx, which may be still running after the handler returned
+	ctx.SetTimeout(timeout)
+	forked := ctx.Fork()
+	done := make(chan struct{})
+	s.inflight.Add(1)
+	detached = true
+	go func() {
+		defer s.inflight.Done()
+		defer releaseGlobal()
+		defer releaseAction()
+		defer close(done)
+		defer recovery.Recover(forked, s.panicHandler(resp))
+		s.runMiddlewares(forked, resp)
+	}()
+
+	select {
+	case <-done:
+		ctx.Error = forked.Error
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			eslog.C(ctx).Warn("request timeout", eslog.Field("Action", action), eslog.Field("Timeout", timeout))
+			ctx.Error = eserrors.RequestTimeout()
+			resp.derive().WithError(ctx.Error).Reply()
+		} else {
+			eslog.C(ctx).Warn("request canceled", eslog.Field("Action", action), eslog.Err(ctx.Err()))
+			ctx.Error = ctx.Err()
+		}
+	}
+}
+
+// throttle run Throttler of middlewares before request body is read, action routed by path or header is set
+func (s *Server) throttle(ctx *core.Context, route map[string]string) error {
+	ctx.Action = route[ParamAction]
+	for _, middleware := range s.Option.Middlewares {
+		if throttler, ok := middleware.(Throttler); ok {
+			if err := throttler.Throttle(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// authenticate run Authenticator of middlewares before the request is dispatched
+func (s *Server) authenticate(ctx *core.Context) error {
+	for _, middleware := range s.Option.Middlewares {
+		if authenticator, ok := middleware.(Authenticator); ok {
+			if err := authenticator.Authenticate(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
+// dispatch resolve Action of request, and return its controller
+func (s *Server) dispatch(ctx *core.Context, route map[string]string) (Controller, error) {
+	value, ok := routeValue(route, ctx.Params, ParamAction)
+	if !ok {
+		return nil, eserrors.MissingParameter(ParamAction)
+	}
+	action, ok := value.(string)
+	if !ok {
+		return nil, eserrors.InvalidParameterValueEx(
+			eserrors.InvalidParameterValueTypeCode,
+			map[string]interface{}{
+				"parameter":  ParamAction,
+				"actualType": valueType(value),
+				"expectType": "string",
+			})
+	}
+	ctx.Action = action
+
+	actionController, err := s.router.DispatchVersion(ctx.Version, action)
+	if err != nil {
+		return nil, err
+	}
+	if actionController == nil {
+		return nil, eserrors.InvalidAction(action)
+	}
+	return actionController, nil
+}
+
+// runMiddlewares run middlewares and controller of ctx,
+// error of middleware which rejects the request before controller is replied
+func (s *Server) runMiddlewares(ctx *core.Context, resp *ServerResponse) {
+	err := ctx.Next()
+	if err != nil && ctx.Error == nil {
+		// request rejected by middleware
+		ctx.Error = err
+	}
+	if err != nil && !resp.sealer.isSealed() {
+		resp.derive().WithError(err).Reply()
+	}
+}
+
+// mergeFieldErrors append errors of validateErr to bindErrs, fields which failed to bind are
+// left zero and their validation errors are dropped
+func mergeFieldErrors(bindErrs *eserrors.MultiError, validateErr error) error {
+	if validateErr == nil {
+		return bindErrs
+	}
+	validateErrs, ok := validateErr.(*eserrors.MultiError)
+	if !ok {
+		return validateErr
+	}
+
+	failed := make(map[string]bool, bindErrs.Len())
+	for _, fieldErr := range bindErrs.Errors() {
+		failed[fieldErr.Field] = true
+	}
+	for _, fieldErr := range validateErrs.Errors() {
+		if !failed[fieldErr.Field] {
+			bindErrs.Append(fieldErr.Field, fieldErr.EsError)
+		}
+	}
+	return bindErrs
+}
+
+// reportAllErrors return whether to report all invalid parameters of r
+func (s *Server) reportAllErrors(r *http.Request) bool {
+	if value := r.Header.Get(ReportAllErrorsHeader); value != "" {
+		if reportAll, err := strconv.ParseBool(value); err == nil {
+			return reportAll
+		}
+	}
+	return s.Option.ReportAllErrors
+}
+
+func (s *Server) panicHandler(resp *ServerResponse) recovery.PanicHandler {
+	return func() {
+		if s.collector != nil {
+			// panic count
+			s.collector.ServerPanicCounter.Inc()
+		}
+		resp.derive().WithError(eserrors.InternalError()).Reply()
+	}
+}
+
+// actionTimeout return timeout of action
+// TimeoutController > ActionMeta.Timeout > ServerOption.ActionTimeouts > ServerOption.RequestTimeout
+func (s *Server) actionTimeout(version, action string, controller Controller) time.Duration {
+	if timeoutController, ok := controller.(TimeoutController); ok {
+		if timeout := timeoutController.Timeout(); timeout > 0 {
+			return timeout
+		}
+	}
+	if meta, ok := s.router.ActionMeta(version, action); ok && meta.Timeout > 0 {
+		return meta.Timeout
+	}
+	if timeout, ok := s.Option.ActionTimeouts[action]; ok {
+		return timeout
+	}
+	return s.Option.RequestTimeout
+}
+
+// Collector return metrics of s, which can be shared with middlewares like NewRateLimitMiddleware
+func (s *Server) Collector() *ServerCollector {
+	return s.collector
+}
+
+// Collectors return metrics of s, mq and storage, pool stats of cache and database are included
+// only if their packages are imported
 func (s *Server) Collectors() []prometheus.Collector {
-	collectors := []prometheus.Collector{mq.DefaultCollector(), storage.DefaultCollector()}
+	collectors := append([]prometheus.Collector{mq.DefaultCollector()}, storage.Collectors()...)
 	if s.collector != nil {
 		collectors = append(collectors, s.collector)
 		return collectors
@@ -434,3 +897,7 @@ func (s *Server) RegisterFilterValidator(set string, key string, validateFunc Fi
 func (s *Server) RegisterCustomValidatorTag(tag string, fn validator.Func) error {
 	return s.validator.RegisterCustomValidatorTag(tag, fn)
 }
+
+func (s *Server) RegisterCustomValidatorTagEx(tag string, fn validator.Func, code, messageTemplate string) error {
+	return s.validator.RegisterCustomValidatorTagEx(tag, fn, code, messageTemplate)
+}
@@ -0,0 +1,317 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SongOf/edge-storage-core/core"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type testDescription struct {
+	BaseDescription
+	Name  string `json:"Name" validate:"required"`
+	Limit int    `json:"Limit" validate:"min=1,max=100"`
+}
+
+type testResponse struct {
+	BaseResponse
+	Name string
+}
+
+type testController struct {
+	description testDescription
+	entry       func(ctx *core.Context, description *testDescription) (ControllerResult, error)
+}
+
+func (c *testController) GetDescription() ControllerDescription {
+	return &c.description
+}
+
+func (c *testController) Entry(ctx *core.Context) (ControllerResult, error) {
+	if c.entry != nil {
+		return c.entry(ctx, &c.description)
+	}
+	return &testResponse{Name: c.description.Name}, nil
+}
+
+type testFactory map[string]func() Controller
+
+func (f testFactory) GetController(action string) Controller {
+	if newController, ok := f[action]; ok {
+		return newController()
+	}
+	return nil
+}
+
+type testReply struct {
+	Response struct {
+		Error *struct {
+			Code    string
+			Message string
+		}
+		Errors []struct {
+			Code    string
+			Message string
+			Field   string
+		}
+		RequestId string
+		Name      string
+	}
+}
+
+func newTestServer(factory testFactory, option ServerOption) *Server {
+	return NewServer(NewRouter(factory), option)
+}
+
+func doRequest(t *testing.T, handler http.Handler, body string, headers ...string) testReply {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return serveRequest(t, handler, r)
+}
+
+func serveRequest(t *testing.T, handler http.Handler, r *http.Request) testReply {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var reply testReply
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("unmarshal reply %q: %v", w.Body.String(), err)
+	}
+	return reply
+}
+
+func TestServer_Handler(t *testing.T) {
+	factory := testFactory{
+		"DescribeTest": func() Controller { return &testController{} },
+	}
+	ping := &Entry{Path: "/ping", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	})}
+
+	// two servers with the same entry must not conflict
+	servers := []*Server{
+		newTestServer(factory, ServerOption{EntryList: []*Entry{ping}}),
+		newTestServer(factory, ServerOption{EntryList: []*Entry{ping}}),
+	}
+
+	for _, s := range servers {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		if w.Body.String() != "pong" {
+			t.Errorf("entry reply = %q, want pong", w.Body.String())
+		}
+
+		reply := doRequest(t, s.Handler(),
+			`{"Action":"DescribeTest","RequestId":"req-1","Name":"edge","Limit":10}`)
+		if reply.Response.Error != nil {
+			t.Fatalf("unexpected error %+v", *reply.Response.Error)
+		}
+		if reply.Response.RequestId != "req-1" || reply.Response.Name != "edge" {
+			t.Errorf("unexpected reply %+v", reply.Response)
+		}
+	}
+}
+
+func TestServer_RequestTimeout(t *testing.T) {
+	finished := make(chan struct{})
+	factory := testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					defer close(finished)
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}
+		},
+	}
+	s := newTestServer(factory, ServerOption{
+		RequestTimeout: time.Hour,
+		ActionTimeouts: map[string]time.Duration{"DescribeTest": 20 * time.Millisecond},
+	})
+
+	reply := doRequest(t, s.Handler(), `{"Action":"DescribeTest","Name":"edge","Limit":10}`)
+	if reply.Response.Error == nil || reply.Response.Error.Code != "RequestTimeout" {
+		t.Fatalf("unexpected reply %+v", reply.Response)
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Error("controller is not canceled")
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(cancel context.CancelFunc)
+	}{
+		{
+			name: "context canceled",
+			stop: func(cancel context.CancelFunc) { cancel() },
+		},
+		{
+			name: "SIGTERM",
+			stop: func(context.CancelFunc) { _ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sock, pidFile := filepath.Join(dir, "es.sock"), filepath.Join(dir, "es.pid")
+			var finished int32
+			factory := testFactory{
+				"DescribeTest": func() Controller {
+					return &testController{
+						entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+							// keep running after request timeout
+							time.Sleep(200 * time.Millisecond)
+							atomic.StoreInt32(&finished, 1)
+							return &testResponse{}, nil
+						},
+					}
+				},
+			}
+			s := newTestServer(factory, ServerOption{
+				ListenAddr:      "unix://" + sock,
+				PidFile:         pidFile,
+				ShutdownTimeout: 5,
+				RequestTimeout:  20 * time.Millisecond,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			runErr := make(chan error, 1)
+			go func() { runErr <- s.Run(ctx) }()
+
+			// PidFile is written after listening
+			for i := 0; ; i++ {
+				if _, err := os.Stat(pidFile); err == nil {
+					break
+				}
+				if i == 100 {
+					t.Fatal("server is not started")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+				},
+			}}
+			resp, err := client.Post("http://es/", "application/json",
+				strings.NewReader(`{"Action":"DescribeTest","Name":"edge","Limit":10}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reply testReply
+			err = json.NewDecoder(resp.Body).Decode(&reply)
+			_ = resp.Body.Close()
+			if err != nil || reply.Response.Error == nil || reply.Response.Error.Code != "RequestTimeout" {
+				t.Fatalf("unexpected reply %+v, error %v", reply.Response, err)
+			}
+
+			tt.stop(cancel)
+			select {
+			case err := <-runErr:
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run() is not returned")
+			}
+			if atomic.LoadInt32(&finished) != 1 {
+				t.Error("Run() returned before in-flight controller finished")
+			}
+			if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+				t.Errorf("pid file is not removed, stat error %v", err)
+			}
+		})
+	}
+}
+
+func TestServer_ReportAllErrors(t *testing.T) {
+	factory := testFactory{
+		"DescribeTest": func() Controller { return &testController{} },
+	}
+
+	tests := []struct {
+		name       string
+		option     ServerOption
+		body       string
+		headers    []string
+		wantFields []string
+	}{
+		{
+			name:       "first error by default",
+			body:       `{"Action":"DescribeTest","Limit":0}`,
+			wantFields: nil,
+		},
+		{
+			name:       "all validation errors",
+			option:     ServerOption{ReportAllErrors: true},
+			body:       `{"Action":"DescribeTest","Limit":0}`,
+			wantFields: []string{"Name", "Limit"},
+		},
+		{
+			name:       "all bind errors by header",
+			body:       `{"Action":"DescribeTest","Name":1,"Limit":"a","Unknown":{"a":[1]}}`,
+			headers:    []string{ReportAllErrorsHeader, "true"},
+			wantFields: []string{"Name", "Limit", "Unknown"},
+		},
+		{
+			name:       "bind and validation errors",
+			option:     ServerOption{ReportAllErrors: true},
+			body:       `{"Action":"DescribeTest","Limit":"a"}`,
+			wantFields: []string{"Limit", "Name"},
+		},
+		{
+			name:       "header disables option",
+			option:     ServerOption{ReportAllErrors: true},
+			body:       `{"Action":"DescribeTest","Limit":0}`,
+			headers:    []string{ReportAllErrorsHeader, "false"},
+			wantFields: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(factory, tt.option)
+			reply := doRequest(t, s.Handler(), tt.body, tt.headers...)
+			if reply.Response.Error == nil {
+				t.Fatalf("unexpected reply %+v", reply.Response)
+			}
+
+			var fields []string
+			for _, err := range reply.Response.Errors {
+				if err.Code == "" || err.Message == "" {
+					t.Errorf("unexpected error %+v", err)
+				}
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+			if len(reply.Response.Errors) > 0 && reply.Response.Errors[0].Code != reply.Response.Error.Code {
+				t.Errorf("Error %+v is not the first of Errors", *reply.Response.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package framework
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequire          = "require"
+	ClientAuthVerifyIfGiven    = "verify_if_given"
+	ClientAuthRequireAndVerify = "require_and_verify"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	ClientAuthNone:             tls.NoClientCert,
+	ClientAuthRequest:          tls.RequestClientCert,
+	ClientAuthRequire:          tls.RequireAnyClientCert,
+	ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type TLSOption struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the CA bundle to verify client certificates
+	ClientCAFile string
+	// ClientAuth is one of none/request/require/verify_if_given/require_and_verify,
+	// defaults to require_and_verify if ClientCAFile is set, otherwise none
+	ClientAuth string
+	// MinVersion is one of 1.0/1.1/1.2/1.3, defaults to 1.2
+	MinVersion string
+	// CipherSuites are names like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, defaults to Go's
+	CipherSuites []string
+	// ReloadInterval is the interval to check whether cert/key/CA files changed,
+	// zero means files are only reloaded on SIGHUP
+	ReloadInterval time.Duration
+}
+
+// buildTLSConfig load certificates and build tls.Config from option
+func buildTLSConfig(option *TLSOption) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(option.CertFile, option.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load key pair: %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if option.MinVersion != "" {
+		version, ok := tlsVersions[option.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version `%s`", option.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(option.CipherSuites) > 0 {
+		suites, err := cipherSuiteIds(option.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = suites
+	}
+
+	clientAuth := option.ClientAuth
+	if clientAuth == "" {
+		clientAuth = ClientAuthNone
+		if option.ClientCAFile != "" {
+			clientAuth = ClientAuthRequireAndVerify
+		}
+	}
+	authType, ok := clientAuthTypes[clientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown client auth `%s`", clientAuth)
+	}
+	config.ClientAuth = authType
+
+	if option.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(option.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in client CA file")
+		}
+		config.ClientCAs = pool
+	} else if authType == tls.VerifyClientCertIfGiven || authType == tls.RequireAndVerifyClientCert {
+		return nil, errors.New("ClientCAFile is required to verify client certificates")
+	}
+
+	return config, nil
+}
+
+func cipherSuiteIds(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite `%s`", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certReloader holds tls.Config built from TLSOption, and rebuild it when files changed,
+// connections established keep using the config at handshake
+type certReloader struct {
+	option *TLSOption
+
+	mu      sync.RWMutex
+	config  *tls.Config
+	modTime time.Time
+}
+
+func newCertReloader(option *TLSOption) (*certReloader, error) {
+	reloader := &certReloader{option: option}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload rebuild tls.Config from files, the current one is kept if failed
+func (reloader *certReloader) Reload() error {
+	modTime := reloader.latestModTime()
+	config, err := buildTLSConfig(reloader.option)
+	if err != nil {
+		return err
+	}
+
+	reloader.mu.Lock()
+	reloader.config, reloader.modTime = config, modTime
+	reloader.mu.Unlock()
+	return nil
+}
+
+// latestModTime return the latest modification time of cert/key/CA files
+func (reloader *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{reloader.option.CertFile, reloader.option.KeyFile, reloader.option.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// changed report whether any file is modified after last reload
+func (reloader *certReloader) changed() bool {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.latestModTime().After(reloader.modTime)
+}
+
+// watch reload files on change every interval until done is closed
+func (reloader *certReloader) watch(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			if !reloader.changed() {
+				continue
+			}
+			if err := reloader.Reload(); err != nil {
+				eslog.L().Error("reload tls certificates failed", eslog.Err(err))
+			} else {
+				eslog.L().Info("tls certificates reloaded")
+			}
+		}
+	}
+}
+
+func (reloader *certReloader) current() *tls.Config {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.config
+}
+
+// TLSConfig return tls.Config for http.Server, which always uses the latest certificates
+func (reloader *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &reloader.current().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := reloader.current().Clone()
+			// same as http.Server.ServeTLS, which only sets NextProtos of http.Server.TLSConfig
+			config.NextProtos = []string{"h2", "http/1.1"}
+			return config, nil
+		},
+	}
+}
@@ -0,0 +1,152 @@
+package framework
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/SongOf/edge-storage-core/core"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", 1, nil)
+	serverCert := newTestCert(t, "server", 2, ca)
+	clientCert := newTestCert(t, "edge-agent", 3, ca)
+
+	option := TLSOption{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	writeFile(t, option.CertFile, serverCert.certPEM)
+	writeFile(t, option.KeyFile, serverCert.keyPEM)
+	writeFile(t, option.ClientCAFile, ca.certPEM)
+
+	factory := testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					return &testResponse{Name: ctx.PeerIdentity().CommonName}, nil
+				},
+			}
+		},
+	}
+	s := newTestServer(factory, ServerOption{ListenAddr: "127.0.0.1:0", TLSOption: option, ShutdownTimeout: 1})
+	listener, err := s.Start(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop(1)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	keyPair, _ := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	newClient := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates},
+		}}
+	}
+	url := "https://" + listener.Addr().String()
+	body := `{"Action":"DescribeTest","Name":"edge","Limit":10}`
+
+	// client without certificate is rejected
+	if _, err := newClient().Post(url, "application/json", strings.NewReader(body)); err == nil {
+		t.Error("request without client certificate should fail")
+	}
+
+	resp, err := newClient(keyPair).Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply testReply
+	_ = json.NewDecoder(resp.Body).Decode(&reply)
+	_ = resp.Body.Close()
+	if reply.Response.Name != "edge-agent" {
+		t.Errorf("peer identity = %q, want edge-agent", reply.Response.Name)
+	}
+	if resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 2 {
+		t.Error("unexpected server certificate")
+	}
+
+	// rotate server certificate
+	rotatedCert := newTestCert(t, "server", 4, ca)
+	writeFile(t, option.CertFile, rotatedCert.certPEM)
+	writeFile(t, option.KeyFile, rotatedCert.keyPEM)
+	if err := s.ReloadCertificates(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = newClient(keyPair).Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 4 {
+		t.Error("server certificate is not reloaded")
+	}
+}
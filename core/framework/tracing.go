@@ -0,0 +1,52 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core"
+	"reflect"
+)
+
+const (
+	// requestSpanName is the name of root span of request before Action is dispatched
+	requestSpanName = "Request"
+
+	// attributes of root span of request
+	RequestIdAttribute = "es.request_id"
+	ActionAttribute    = "es.action"
+	VersionAttribute   = "es.version"
+)
+
+type tracedMiddleware struct {
+	name       string
+	middleware core.Middleware
+}
+
+// traceMiddlewares wrap each middleware in a span named by its type,
+// the span covers the rest of middlewares and controller called by ctx.Next()
+func traceMiddlewares(middlewares ...core.Middleware) []core.Middleware {
+	traced := make([]core.Middleware, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		traced = append(traced, &tracedMiddleware{
+			name:       "Middleware " + middlewareName(middleware),
+			middleware: middleware,
+		})
+	}
+	return traced
+}
+
+func (middleware *tracedMiddleware) Run(ctx *core.Context) (err error) {
+	end := ctx.StartSpan(middleware.name)
+	defer func() { end(err) }()
+	return middleware.middleware.Run(ctx)
+}
+
+func middlewareName(middleware core.Middleware) string {
+	t := reflect.TypeOf(middleware)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return fmt.Sprintf("%T", middleware)
+}
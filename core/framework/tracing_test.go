@@ -0,0 +1,137 @@
+package framework
+
+import (
+	"errors"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/core/framework/chain"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTest(*core.Context) error { return nil }
+func deleteTest(*core.Context) error { return nil }
+func attachTest(*core.Context) error { return errors.New("attach failed") }
+
+func TestServer_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := sdktrace.NewProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithSyncer(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.SetTraceProvider(provider)
+	defer global.SetTraceProvider(trace.NoopProvider{})
+
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					return nil, chain.NewChain(
+						chain.NewUnit(createTest, deleteTest),
+						chain.NewUnit(attachTest, nil),
+					).Run(ctx)
+				},
+			}
+		},
+	}, ServerOption{Middlewares: []core.Middleware{testAppIdMiddleware(1)}})
+
+	const (
+		traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanId  = "00f067aa0ba902b7"
+	)
+	doRequest(t, s.Handler(), `{"Action":"DescribeTest","Name":"a","Limit":1}`,
+		"traceparent", "00-"+traceId+"-"+spanId+"-01")
+
+	spans := make(map[string]bool)
+	for _, span := range exporter.GetSpans() {
+		name := span.Name
+		if i := strings.LastIndex(name, "."); strings.HasPrefix(name, "Unit ") || strings.HasPrefix(name, "Rollback ") {
+			// function names are prefixed by package
+			name = name[:strings.Index(name, " ")+1] + name[i+1:]
+		}
+		spans[name] = true
+
+		if got := span.SpanContext.TraceID.String(); got != traceId {
+			t.Errorf("trace id of span %s = %s, want %s", span.Name, got, traceId)
+		}
+		if name == "DescribeTest" && span.ParentSpanID.String() != spanId {
+			t.Errorf("parent of request span = %s, want %s", span.ParentSpanID, spanId)
+		}
+		if name == "Unit attachTest" && span.StatusMessage != "attach failed" {
+			t.Errorf("status of failed unit = %q", span.StatusMessage)
+		}
+	}
+	for _, name := range []string{
+		"DescribeTest", "Parse", "Dispatch", "CheckParams", "ValidateParameters",
+		"Middleware testAppIdMiddleware", "Controller", "Unit createTest", "Unit attachTest", "Rollback deleteTest",
+	} {
+		if !spans[name] {
+			t.Errorf("span %s not found in %v", name, spans)
+		}
+	}
+}
+
+func TestServer_TracingTimeout(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := sdktrace.NewProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithSyncer(exporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	global.SetTraceProvider(provider)
+	defer global.SetTraceProvider(trace.NoopProvider{})
+
+	s := newTestServer(testFactory{
+		"DescribeTest": func() Controller {
+			return &testController{
+				entry: func(ctx *core.Context, description *testDescription) (ControllerResult, error) {
+					<-ctx.Done()
+					// spans are started and ended after the request span
+					for i := 0; i < 10; i++ {
+						if err := chain.NewChain(chain.NewUnit(createTest, deleteTest)).Run(ctx); err != nil {
+							return nil, err
+						}
+					}
+					return nil, attachTest(ctx)
+				},
+			}
+		},
+	}, ServerOption{
+		ActionTimeouts: map[string]time.Duration{"DescribeTest": 20 * time.Millisecond},
+		Middlewares:    []core.Middleware{testAppIdMiddleware(1)},
+	})
+
+	reply := doRequest(t, s.Handler(), `{"Action":"DescribeTest","Name":"a","Limit":1}`)
+	if reply.Response.Error == nil || reply.Response.Error.Code != "RequestTimeout" {
+		t.Fatalf("unexpected reply %+v, want RequestTimeout", reply.Response)
+	}
+	s.inflight.Wait()
+
+	spans := make(map[string]*exporttrace.SpanData)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	request, controller := spans["DescribeTest"], spans["Controller"]
+	if request == nil || controller == nil {
+		t.Fatalf("spans of request and controller not found in %v", spans)
+	}
+	if !strings.HasPrefix(request.StatusMessage, "RequestTimeout") {
+		t.Errorf("status of request span = %q, want RequestTimeout", request.StatusMessage)
+	}
+	if controller.StatusMessage != "attach failed" {
+		t.Errorf("status of controller span = %q, want attach failed", controller.StatusMessage)
+	}
+	middleware := spans["Middleware testAppIdMiddleware"]
+	if middleware == nil || middleware.ParentSpanID != request.SpanContext.SpanID ||
+		controller.ParentSpanID != middleware.SpanContext.SpanID {
+		t.Errorf("spans of detached controller are not children of request span")
+	}
+}
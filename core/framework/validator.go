@@ -7,6 +7,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"reflect"
 	"strings"
+	"sync"
 )
 
 type FilterValidateFunc func(value interface{}) bool
@@ -18,6 +19,7 @@ func NewValidator() (*Validator, error) {
 	validatorObj := &Validator{
 		validate:          mValidator,
 		filterValidateMap: make(map[string]FilterValueValidateFuncMap),
+		customTags:        make(map[string]customTag),
 	}
 
 	err := mValidator.RegisterValidation("filter_key_validator", func(fl validator.FieldLevel) bool {
@@ -69,6 +71,16 @@ func NewValidator() (*Validator, error) {
 type Validator struct {
 	validate          *validator.Validate
 	filterValidateMap map[string]FilterValueValidateFuncMap
+
+	// customTags are custom tags registered with their own error, they are scoped to the validator
+	customTagsMu sync.RWMutex
+	customTags   map[string]customTag
+}
+
+// customTag is the error of a custom tag, reported as InvalidParameterValue.<code>
+type customTag struct {
+	code            string
+	messageTemplate string
 }
 
 func (v *Validator) RegisterFilterValidator(set string, key string, validateFunc FilterValidateFunc) {
@@ -86,6 +98,28 @@ func (v *Validator) RegisterCustomValidatorTag(tag string, fn validator.Func) er
 	return v.validate.RegisterValidation(tag, fn, false)
 }
 
+//RegisterCustomValidatorTagEx register custom validator Tag, whose failure is reported as
+//InvalidParameterValue.<code>, messageTemplate can refer to `{{.value}}`, `{{.parameter}}` and `{{.param}}`.
+//code must not be a framework error code, or a code of another custom tag with different messageTemplate
+func (v *Validator) RegisterCustomValidatorTagEx(tag string, fn validator.Func, code, messageTemplate string) error {
+	if eserrors.IsFrameworkCode(code) {
+		return fmt.Errorf("error code `%s` of tag `%s` conflicts with framework error code", code, tag)
+	}
+
+	v.customTagsMu.Lock()
+	defer v.customTagsMu.Unlock()
+	for otherTag, other := range v.customTags {
+		if otherTag != tag && other.code == code && other.messageTemplate != messageTemplate {
+			return fmt.Errorf("error code `%s` of tag `%s` conflicts with tag `%s`", code, tag, otherTag)
+		}
+	}
+	if err := v.validate.RegisterValidation(tag, fn, false); err != nil {
+		return err
+	}
+	v.customTags[tag] = customTag{code: code, messageTemplate: messageTemplate}
+	return nil
+}
+
 //ValidateParameters validate description
 func (v *Validator) ValidateParameters(ctx context.Context, description ControllerDescription) error {
 	if description == nil {
@@ -107,6 +141,39 @@ func (v *Validator) ValidateParameters(ctx context.Context, description Controll
 	return nil
 }
 
+//ValidateAllParameters validate description and return all violations by eserrors.MultiError
+func (v *Validator) ValidateAllParameters(ctx context.Context, description ControllerDescription) error {
+	if description == nil {
+		return fmt.Errorf("description is nil")
+	}
+
+	err := v.validate.Struct(description)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*validator.InvalidValidationError); ok {
+		return fmt.Errorf("validate error:%v", err)
+	}
+
+	errs := eserrors.NewMultiError()
+	for _, fieldErr := range err.(validator.ValidationErrors) {
+		transErr := v.translate(fieldErr)
+		esErr, ok := transErr.(eserrors.EsError)
+		if !ok {
+			return transErr
+		}
+		errs.Append(fieldPath(fieldErr.Namespace()), esErr)
+	}
+	return errs.ErrorOrNil()
+}
+
+// fieldPath convert validator namespace like `Description.Filters[0].Name` to `Filters.0.Name`
+func fieldPath(namespace string) string {
+	fields := strings.SplitN(namespace, ".", 2)
+	path := fields[len(fields)-1]
+	return strings.NewReplacer("[", ".", "]", "").Replace(path)
+}
+
 func (v *Validator) translate(err validator.FieldError) error {
 	fields := strings.SplitN(err.Namespace(), ".", 2)
 	if len(fields) < 1 {
@@ -116,6 +183,21 @@ func (v *Validator) translate(err validator.FieldError) error {
 
 	namespace := fields[len(fields)-1]
 
+	v.customTagsMu.RLock()
+	custom, ok := v.customTags[err.Tag()]
+	v.customTagsMu.RUnlock()
+	if ok {
+		return eserrors.InvalidParameterValueWithMessage(
+			custom.code,
+			custom.messageTemplate,
+			map[string]interface{}{
+				"value":     err.Value(),
+				"parameter": namespace,
+				"param":     err.Param(),
+			},
+		)
+	}
+
 	var transErr error
 	switch err.Tag() {
 	case "required":
@@ -178,68 +260,8 @@ func (v *Validator) translate(err validator.FieldError) error {
 			},
 		)
 
-	case "eqfield":
-		transErr = eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueFieldsCompareCode,
-			map[string]interface{}{
-				"leftField":  namespace,
-				"rightField": err.Param(),
-				"relation":   "equal to",
-			},
-		)
-
-	case "nefield":
-		transErr = eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueFieldsCompareCode,
-			map[string]interface{}{
-				"leftField":  namespace,
-				"rightField": err.Param(),
-				"relation":   "not equal to",
-			},
-		)
-
-	case "gtfield":
-		transErr = eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueFieldsCompareCode,
-			map[string]interface{}{
-				"leftField":  namespace,
-				"rightField": err.Param(),
-				"relation":   "greater than",
-			},
-		)
-
-	case "gtefield":
-		transErr = eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueFieldsCompareCode,
-			map[string]interface{}{
-				"leftField":  namespace,
-				"rightField": err.Param(),
-				"relation":   "greater than or equal to",
-			},
-		)
-
-	case "ltfield":
-		transErr = eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueFieldsCompareCode,
-			map[string]interface{}{
-				"leftField":  namespace,
-				"rightField": err.Param(),
-				"relation":   "less than",
-			},
-		)
-
-	case "ltefield":
-		transErr = eserrors.InvalidParameterValueEx(
-			eserrors.InvalidParameterValueFieldsCompareCode,
-			map[string]interface{}{
-				"leftField":  namespace,
-				"rightField": err.Param(),
-				"relation":   "less than or equal to",
-			},
-		)
-
 	default:
-		transErr = eserrors.InvalidParameterValue(namespace, err.Value())
+		transErr = translateTag(err, namespace)
 	}
 
 	return transErr
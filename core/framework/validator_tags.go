@@ -0,0 +1,239 @@
+package framework
+
+import (
+	"fmt"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/go-playground/validator/v10"
+	"strings"
+)
+
+// compareRelations are relations of value and tag param of comparison tags
+var compareRelations = map[string]string{
+	"eq":  "equal to",
+	"ne":  "not equal to",
+	"gt":  "greater than",
+	"gte": "greater than or equal to",
+	"lt":  "less than",
+	"lte": "less than or equal to",
+}
+
+// fieldRelations are relations of field and the other field of cross field tags
+var fieldRelations = map[string]string{
+	"eqfield":       "equal to",
+	"nefield":       "not equal to",
+	"gtfield":       "greater than",
+	"gtefield":      "greater than or equal to",
+	"ltfield":       "less than",
+	"ltefield":      "less than or equal to",
+	"eqcsfield":     "equal to",
+	"necsfield":     "not equal to",
+	"gtcsfield":     "greater than",
+	"gtecsfield":    "greater than or equal to",
+	"ltcsfield":     "less than",
+	"ltecsfield":    "less than or equal to",
+	"fieldcontains": "contain",
+	"fieldexcludes": "not contain",
+}
+
+// contentRelations are relations of value and tag param of string content tags
+var contentRelations = map[string]string{
+	"contains":      "contain",
+	"containsany":   "contain any of",
+	"containsrune":  "contain",
+	"excludes":      "not contain",
+	"excludesall":   "not contain any of",
+	"excludesrune":  "not contain",
+	"startswith":    "start with",
+	"startsnotwith": "not start with",
+	"endswith":      "end with",
+	"endsnotwith":   "not end with",
+}
+
+// tagFormat is the secondary code and description of a format tag
+type tagFormat struct {
+	code   string
+	format string
+}
+
+var formatTags = map[string]tagFormat{
+	"email": {eserrors.InvalidParameterValueEmailCode, "email address"},
+
+	"url":         {eserrors.InvalidParameterValueUrlCode, "URL"},
+	"uri":         {eserrors.InvalidParameterValueUrlCode, "URI"},
+	"urn_rfc2141": {eserrors.InvalidParameterValueUrlCode, "URN"},
+	"datauri":     {eserrors.InvalidParameterValueUrlCode, "data URI"},
+
+	"uuid":          {eserrors.InvalidParameterValueUuidCode, "UUID"},
+	"uuid3":         {eserrors.InvalidParameterValueUuidCode, "UUID v3"},
+	"uuid4":         {eserrors.InvalidParameterValueUuidCode, "UUID v4"},
+	"uuid5":         {eserrors.InvalidParameterValueUuidCode, "UUID v5"},
+	"uuid_rfc4122":  {eserrors.InvalidParameterValueUuidCode, "RFC4122 UUID"},
+	"uuid3_rfc4122": {eserrors.InvalidParameterValueUuidCode, "RFC4122 UUID v3"},
+	"uuid4_rfc4122": {eserrors.InvalidParameterValueUuidCode, "RFC4122 UUID v4"},
+	"uuid5_rfc4122": {eserrors.InvalidParameterValueUuidCode, "RFC4122 UUID v5"},
+
+	"ip":   {eserrors.InvalidParameterValueIpCode, "IP address"},
+	"ipv4": {eserrors.InvalidParameterValueIpCode, "IPv4 address"},
+	"ipv6": {eserrors.InvalidParameterValueIpCode, "IPv6 address"},
+
+	"cidr":   {eserrors.InvalidParameterValueCidrCode, "CIDR"},
+	"cidrv4": {eserrors.InvalidParameterValueCidrCode, "IPv4 CIDR"},
+	"cidrv6": {eserrors.InvalidParameterValueCidrCode, "IPv6 CIDR"},
+
+	"mac": {eserrors.InvalidParameterValueMacCode, "MAC address"},
+
+	"hostname":         {eserrors.InvalidParameterValueHostnameCode, "hostname"},
+	"hostname_rfc1123": {eserrors.InvalidParameterValueHostnameCode, "RFC1123 hostname"},
+	"fqdn":             {eserrors.InvalidParameterValueHostnameCode, "fully qualified domain name"},
+
+	"hostname_port": {eserrors.InvalidParameterValueNetAddressCode, "host:port address"},
+	"tcp_addr":      {eserrors.InvalidParameterValueNetAddressCode, "TCP address"},
+	"tcp4_addr":     {eserrors.InvalidParameterValueNetAddressCode, "TCPv4 address"},
+	"tcp6_addr":     {eserrors.InvalidParameterValueNetAddressCode, "TCPv6 address"},
+	"udp_addr":      {eserrors.InvalidParameterValueNetAddressCode, "UDP address"},
+	"udp4_addr":     {eserrors.InvalidParameterValueNetAddressCode, "UDPv4 address"},
+	"udp6_addr":     {eserrors.InvalidParameterValueNetAddressCode, "UDPv6 address"},
+	"ip_addr":       {eserrors.InvalidParameterValueNetAddressCode, "resolvable IP address"},
+	"ip4_addr":      {eserrors.InvalidParameterValueNetAddressCode, "resolvable IPv4 address"},
+	"ip6_addr":      {eserrors.InvalidParameterValueNetAddressCode, "resolvable IPv6 address"},
+	"unix_addr":     {eserrors.InvalidParameterValueNetAddressCode, "unix socket address"},
+
+	"alpha":           {eserrors.InvalidParameterValueFormatCode, "alphabetic string"},
+	"alphanum":        {eserrors.InvalidParameterValueFormatCode, "alphanumeric string"},
+	"alphaunicode":    {eserrors.InvalidParameterValueFormatCode, "unicode alphabetic string"},
+	"alphanumunicode": {eserrors.InvalidParameterValueFormatCode, "unicode alphanumeric string"},
+	"numeric":         {eserrors.InvalidParameterValueFormatCode, "numeric string"},
+	"number":          {eserrors.InvalidParameterValueFormatCode, "number string"},
+	"hexadecimal":     {eserrors.InvalidParameterValueFormatCode, "hexadecimal string"},
+	"ascii":           {eserrors.InvalidParameterValueFormatCode, "ASCII string"},
+	"printascii":      {eserrors.InvalidParameterValueFormatCode, "printable ASCII string"},
+	"multibyte":       {eserrors.InvalidParameterValueFormatCode, "multibyte string"},
+	"lowercase":       {eserrors.InvalidParameterValueFormatCode, "lowercase string"},
+	"uppercase":       {eserrors.InvalidParameterValueFormatCode, "uppercase string"},
+	"base64":          {eserrors.InvalidParameterValueFormatCode, "base64 string"},
+	"base64url":       {eserrors.InvalidParameterValueFormatCode, "base64 URL string"},
+	"html":            {eserrors.InvalidParameterValueFormatCode, "HTML string"},
+	"html_encoded":    {eserrors.InvalidParameterValueFormatCode, "HTML encoded string"},
+	"url_encoded":     {eserrors.InvalidParameterValueFormatCode, "URL encoded string"},
+	"json":            {eserrors.InvalidParameterValueFormatCode, "JSON string"},
+	"jwt":             {eserrors.InvalidParameterValueFormatCode, "JWT"},
+	"hexcolor":        {eserrors.InvalidParameterValueFormatCode, "hex color"},
+	"rgb":             {eserrors.InvalidParameterValueFormatCode, "RGB color"},
+	"rgba":            {eserrors.InvalidParameterValueFormatCode, "RGBA color"},
+	"hsl":             {eserrors.InvalidParameterValueFormatCode, "HSL color"},
+	"hsla":            {eserrors.InvalidParameterValueFormatCode, "HSLA color"},
+	"iscolor":         {eserrors.InvalidParameterValueFormatCode, "color"},
+	"e164":            {eserrors.InvalidParameterValueFormatCode, "E.164 phone number"},
+	"isbn":            {eserrors.InvalidParameterValueFormatCode, "ISBN"},
+	"isbn10":          {eserrors.InvalidParameterValueFormatCode, "ISBN-10"},
+	"isbn13":          {eserrors.InvalidParameterValueFormatCode, "ISBN-13"},
+	"eth_addr":        {eserrors.InvalidParameterValueFormatCode, "Ethereum address"},
+	"btc_addr":        {eserrors.InvalidParameterValueFormatCode, "Bitcoin address"},
+	"btc_addr_bech32": {eserrors.InvalidParameterValueFormatCode, "Bech32 Bitcoin address"},
+	"latitude":        {eserrors.InvalidParameterValueFormatCode, "latitude"},
+	"longitude":       {eserrors.InvalidParameterValueFormatCode, "longitude"},
+	"ssn":             {eserrors.InvalidParameterValueFormatCode, "SSN"},
+	"timezone":        {eserrors.InvalidParameterValueFormatCode, "time zone"},
+	"file":            {eserrors.InvalidParameterValueFormatCode, "existing file"},
+	"dir":             {eserrors.InvalidParameterValueFormatCode, "existing directory"},
+	"bic":             {eserrors.InvalidParameterValueFormatCode, "BIC"},
+
+	"bcp47_language_tag":            {eserrors.InvalidParameterValueFormatCode, "BCP 47 language tag"},
+	"country_code":                  {eserrors.InvalidParameterValueFormatCode, "country code"},
+	"iso3166_1_alpha2":              {eserrors.InvalidParameterValueFormatCode, "ISO 3166-1 alpha-2 country code"},
+	"iso3166_1_alpha3":              {eserrors.InvalidParameterValueFormatCode, "ISO 3166-1 alpha-3 country code"},
+	"iso3166_1_alpha_numeric":       {eserrors.InvalidParameterValueFormatCode, "ISO 3166-1 numeric country code"},
+	"iso3166_2":                     {eserrors.InvalidParameterValueFormatCode, "ISO 3166-2 subdivision code"},
+	"postcode_iso3166_alpha2":       {eserrors.InvalidParameterValueFormatCode, "postcode"},
+	"postcode_iso3166_alpha2_field": {eserrors.InvalidParameterValueFormatCode, "postcode"},
+}
+
+// translateTag translate errors of tags not handled by Validator.translate,
+// tags unknown here are translated to InvalidParameterValue
+func translateTag(err validator.FieldError, namespace string) error {
+	tag := err.Tag()
+	data := map[string]interface{}{
+		"value":     err.Value(),
+		"parameter": namespace,
+		"param":     err.Param(),
+	}
+
+	if relation, ok := compareRelations[tag]; ok {
+		data["relation"] = relation
+		return eserrors.InvalidParameterValueEx(eserrors.InvalidParameterValueCompareCode, data)
+	}
+	if relation, ok := fieldRelations[tag]; ok {
+		return eserrors.InvalidParameterValueEx(
+			eserrors.InvalidParameterValueFieldsCompareCode,
+			map[string]interface{}{
+				"leftField":  namespace,
+				"rightField": err.Param(),
+				"relation":   relation,
+			},
+		)
+	}
+	if relation, ok := contentRelations[tag]; ok {
+		data["relation"] = relation
+		return eserrors.InvalidParameterValueEx(eserrors.InvalidParameterValueContentCode, data)
+	}
+	if format, ok := formatTags[tag]; ok {
+		data["format"] = format.format
+		return eserrors.InvalidParameterValueEx(format.code, data)
+	}
+
+	switch tag {
+	case "datetime":
+		return eserrors.InvalidParameterValueEx(eserrors.InvalidParameterValueDatetimeCode, data)
+
+	case "unique":
+		return eserrors.InvalidParameterValueEx(eserrors.InvalidParameterValueDuplicateCode, data)
+
+	case "isdefault":
+		data["condition"] = ""
+		return eserrors.InvalidParameterEx(eserrors.InvalidParameterExcludedCode, data)
+
+	case "required_if", "required_unless", "required_with", "required_with_all",
+		"required_without", "required_without_all":
+		data["condition"] = tagCondition(tag, err.Param())
+		return eserrors.MissingParameterEx(eserrors.MissingParameterConditionalCode, data)
+
+	case "excluded_if", "excluded_unless", "excluded_with", "excluded_with_all",
+		"excluded_without", "excluded_without_all":
+		data["condition"] = tagCondition(tag, err.Param())
+		return eserrors.InvalidParameterEx(eserrors.InvalidParameterExcludedCode, data)
+	}
+
+	return eserrors.InvalidParameterValue(namespace, err.Value())
+}
+
+// tagCondition describe condition of required_* and excluded_* tags, e.g.
+// `required_if=Type disk` is described as "if `Type` is `disk`"
+func tagCondition(tag, param string) string {
+	fields := strings.Fields(param)
+	quoted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		quoted = append(quoted, "`"+field+"`")
+	}
+
+	switch strings.SplitN(tag, "_", 2)[1] {
+	case "if", "unless":
+		conditions := make([]string, 0, len(fields)/2)
+		for i := 0; i+1 < len(quoted); i += 2 {
+			conditions = append(conditions, fmt.Sprintf("%s is %s", quoted[i], quoted[i+1]))
+		}
+		keyword := "if"
+		if strings.HasSuffix(tag, "unless") {
+			keyword = "unless"
+		}
+		return keyword + " " + strings.Join(conditions, " and ")
+	case "with":
+		return "if any of " + strings.Join(quoted, ", ") + " is specified"
+	case "with_all":
+		return "if all of " + strings.Join(quoted, ", ") + " are specified"
+	case "without":
+		return "if any of " + strings.Join(quoted, ", ") + " is not specified"
+	case "without_all":
+		return "if none of " + strings.Join(quoted, ", ") + " is specified"
+	}
+	return ""
+}
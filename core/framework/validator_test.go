@@ -0,0 +1,159 @@
+package framework
+
+import (
+	"context"
+	"github.com/SongOf/edge-storage-core/core/eserrors"
+	"github.com/go-playground/validator/v10"
+	"strings"
+	"testing"
+)
+
+type testValidateDescription struct {
+	BaseDescription
+	Email    string   `validate:"omitempty,email"`
+	Id       string   `validate:"omitempty,uuid4"`
+	Ip       string   `validate:"omitempty,ip"`
+	Cidr     string   `validate:"omitempty,cidr"`
+	Url      string   `validate:"omitempty,url"`
+	Count    int      `validate:"omitempty,gt=10"`
+	Prefix   string   `validate:"omitempty,startswith=es-"`
+	Password string   `validate:"required_with=Username"`
+	Username string   `validate:"omitempty,lowercase"`
+	Zones    []string `validate:"omitempty,unique,dive,zone"`
+}
+
+func TestValidator_Translate(t *testing.T) {
+	v, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.RegisterCustomValidatorTagEx("zone", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "ap-")
+	}, "Zone", "The zone `{{.value}}` specified in the parameter `{{.parameter}}` is not available.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		description *testValidateDescription
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "email",
+			description: &testValidateDescription{Email: "a"},
+			wantCode:    "InvalidParameterValue.Email",
+			wantMessage: "The value `a` specified in the parameter `Email` is not a valid email address.",
+		},
+		{
+			name:        "uuid",
+			description: &testValidateDescription{Id: "a"},
+			wantCode:    "InvalidParameterValue.Uuid",
+			wantMessage: "The value `a` specified in the parameter `Id` is not a valid UUID v4.",
+		},
+		{
+			name:        "ip",
+			description: &testValidateDescription{Ip: "a"},
+			wantCode:    "InvalidParameterValue.Ip",
+		},
+		{
+			name:        "cidr",
+			description: &testValidateDescription{Cidr: "10.0.0.1"},
+			wantCode:    "InvalidParameterValue.Cidr",
+		},
+		{
+			name:        "url",
+			description: &testValidateDescription{Url: "a"},
+			wantCode:    "InvalidParameterValue.Url",
+		},
+		{
+			name:        "gt",
+			description: &testValidateDescription{Count: 1},
+			wantCode:    "InvalidParameterValue.Compare",
+			wantMessage: "The value `1` specified in the parameter `Count` must be greater than `10`.",
+		},
+		{
+			name:        "startswith",
+			description: &testValidateDescription{Prefix: "a"},
+			wantCode:    "InvalidParameterValue.Content",
+			wantMessage: "The value `a` specified in the parameter `Prefix` must start with `es-`.",
+		},
+		{
+			name:        "required_with",
+			description: &testValidateDescription{Username: "admin"},
+			wantCode:    "MissingParameter.Conditional",
+			wantMessage: "The request is missing a parameter `Password`, which is required if any of `Username` is specified.",
+		},
+		{
+			name:        "format",
+			description: &testValidateDescription{Password: "x", Username: "Admin"},
+			wantCode:    "InvalidParameterValue.Format",
+			wantMessage: "The value `Admin` specified in the parameter `Username` is not a valid lowercase string.",
+		},
+		{
+			name:        "unique",
+			description: &testValidateDescription{Zones: []string{"ap-1", "ap-1"}},
+			wantCode:    "InvalidParameterValue.Duplicate",
+		},
+		{
+			name:        "custom tag in dive",
+			description: &testValidateDescription{Zones: []string{"ap-1", "eu-1"}},
+			wantCode:    "InvalidParameterValue.Zone",
+			wantMessage: "The zone `eu-1` specified in the parameter `Zones[1]` is not available.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateParameters(context.Background(), tt.description)
+			esErr, ok := err.(eserrors.EsError)
+			if !ok {
+				t.Fatalf("ValidateParameters() error = %v, want EsError", err)
+			}
+			code, message := esErr.Format()
+			if code != tt.wantCode {
+				t.Errorf("ValidateParameters() code = %s, want %s", code, tt.wantCode)
+			}
+			if message == "" || (tt.wantMessage != "" && message != tt.wantMessage) {
+				t.Errorf("ValidateParameters() message = %q, want %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidator_RegisterCustomValidatorTagEx(t *testing.T) {
+	isAp := func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "ap-")
+	}
+
+	v, _ := NewValidator()
+	if err := v.RegisterCustomValidatorTagEx("zone", isAp, eserrors.InvalidParameterValueEmailCode, "x"); err == nil {
+		t.Error("framework error code should be rejected")
+	}
+	if err := v.RegisterCustomValidatorTagEx("zone", isAp, "Zone", "The zone `{{.value}}` is invalid."); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.RegisterCustomValidatorTagEx("region", isAp, "Zone", "The region is invalid."); err == nil {
+		t.Error("error code of another tag with different message should be rejected")
+	}
+
+	// messages of the same code are scoped to each validator
+	other, _ := NewValidator()
+	if err := other.RegisterCustomValidatorTagEx("zone", isAp, "Zone", "Zone `{{.value}}` is sold out."); err != nil {
+		t.Fatal(err)
+	}
+	for tagValidator, want := range map[*Validator]string{
+		v:     "The zone `eu-1` is invalid.",
+		other: "Zone `eu-1` is sold out.",
+	} {
+		err := tagValidator.ValidateParameters(context.Background(), &testValidateDescription{Zones: []string{"eu-1"}})
+		esErr, ok := err.(eserrors.EsError)
+		if !ok {
+			t.Fatalf("ValidateParameters() error = %v, want EsError", err)
+		}
+		if _, message := esErr.Format(); message != want {
+			t.Errorf("ValidateParameters() message = %q, want %q", message, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package core
+
+import "crypto/x509"
+
+// PeerIdentity is the identity of a client verified by mutual TLS
+type PeerIdentity struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	URIs         []string
+	SerialNumber string
+	// Certificate is the leaf certificate of the verified chain
+	Certificate *x509.Certificate
+}
+
+// PeerIdentity return identity of the client certificate verified by server,
+// nil if the request is not over TLS or client certificate is not verified
+func (ctx *Context) PeerIdentity() *PeerIdentity {
+	if ctx.Request == nil || ctx.Request.TLS == nil {
+		return nil
+	}
+
+	chains := ctx.Request.TLS.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+
+	cert := chains[0][0]
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+	return &PeerIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		URIs:         uris,
+		SerialNumber: cert.SerialNumber.String(),
+		Certificate:  cert,
+	}
+}
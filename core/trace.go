@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// TracerName is the name of tracer which starts spans of Context
+const TracerName = "github.com/SongOf/edge-storage-core"
+
+// StartSpan start a span as child of the current span of ctx, and make it the current span.
+// The returned end finishes the span with err, and restores the previous span,
+// it must be called in the reverse order of StartSpan.
+// The current span is shared by users of ctx, goroutines started by request should start spans on ctx.Fork().
+// Spans are not recorded until a trace provider is set by estrace.Init
+func (ctx *Context) StartSpan(name string, opts ...trace.StartOption) (end func(err error)) {
+	_, span := global.Tracer(TracerName).Start(ctx, name, opts...)
+
+	ctx.mu.Lock()
+	prev := ctx.spanCtx
+	// span is stored in an empty context, so that lookups of other keys fall back to ctx.base()
+	ctx.spanCtx = trace.ContextWithSpan(context.Background(), span)
+	ctx.mu.Unlock()
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(ctx, err)
+			span.SetStatus(codes.Unknown, err.Error())
+		}
+		span.End()
+
+		ctx.mu.Lock()
+		ctx.spanCtx = prev
+		ctx.mu.Unlock()
+	}
+}
+
+// Span return the current span of ctx, it's a noop span if no span is started
+func (ctx *Context) Span() trace.Span {
+	return trace.SpanFromContext(ctx)
+}
+
+func (ctx *Context) spanValue(key interface{}) interface{} {
+	ctx.mu.RLock()
+	spanCtx := ctx.spanCtx
+	ctx.mu.RUnlock()
+	if spanCtx == nil {
+		return nil
+	}
+	return spanCtx.Value(key)
+}
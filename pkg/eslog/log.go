@@ -3,6 +3,7 @@ package eslog
 import (
 	"context"
 	"github.com/SongOf/edge-storage-core/core"
+	"go.opentelemetry.io/otel/api/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -101,11 +102,15 @@ func (es *EsLogger) copyWithField(logFields map[string]interface{}) *EsLogger {
 	}
 }
 
+// C return logger with LogFields of ctx, and trace_id and span_id of the current span if it's recorded
 func C(ctx context.Context) *EsLogger {
 	if ctx == nil {
 		return &esLogger
 	}
+	return withSpan(ctx, ctxLogger(ctx))
+}
 
+func ctxLogger(ctx context.Context) *EsLogger {
 	ctxLogger := ctx.Value(ctxLoggerName)
 	if ctxLogger == nil {
 		if coreCtx := core.Cast(ctx); coreCtx != nil {
@@ -118,6 +123,18 @@ func C(ctx context.Context) *EsLogger {
 	return ctxLogger.(*EsLogger)
 }
 
+// withSpan add ids of the current span to logger, they are not cached because span changes with stages of request
+func withSpan(ctx context.Context, logger *EsLogger) *EsLogger {
+	spanContext := trace.SpanFromContext(ctx).SpanContext()
+	if !spanContext.IsValid() {
+		return logger
+	}
+	return logger.copyWithField(map[string]interface{}{
+		"trace_id": spanContext.TraceID.String(),
+		"span_id":  spanContext.SpanID.String(),
+	})
+}
+
 func Field(key string, value interface{}) zap.Field {
 	return zap.Any(key, value)
 }
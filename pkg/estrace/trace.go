@@ -0,0 +1,95 @@
+package estrace
+
+import (
+	"fmt"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"sync"
+)
+
+const DefaultEndpoint = "localhost:55680"
+
+type TraceOption struct {
+	// Endpoint is the address of OTLP collector, default is DefaultEndpoint
+	Endpoint string
+	// Insecure disables TLS to collector, it's usually set for a local collector
+	Insecure bool
+	// ServiceName is reported as service.name of spans
+	ServiceName string
+	// SampleRatio is the ratio of traces sampled when request has no sampled parent, default is 1
+	SampleRatio float64
+	// Labels are extra resource labels of spans, like environment or region
+	Labels map[string]string
+}
+
+var (
+	mu        sync.Mutex
+	provider  *sdktrace.Provider
+	processor *sdktrace.BatchSpanProcessor
+	exporter  *otlp.Exporter
+)
+
+// Init export spans to OTLP collector, and set the global trace provider used by core.Context
+func Init(option TraceOption) error {
+	if option.Endpoint == "" {
+		option.Endpoint = DefaultEndpoint
+	}
+	if option.SampleRatio <= 0 || option.SampleRatio > 1 {
+		option.SampleRatio = 1
+	}
+
+	exporterOptions := []otlp.ExporterOption{otlp.WithAddress(option.Endpoint)}
+	if option.Insecure {
+		exporterOptions = append(exporterOptions, otlp.WithInsecure())
+	}
+	newExporter, err := otlp.NewExporter(exporterOptions...)
+	if err != nil {
+		return fmt.Errorf("create otlp exporter failed: %w", err)
+	}
+	newProcessor, err := sdktrace.NewBatchSpanProcessor(newExporter)
+	if err != nil {
+		_ = newExporter.Stop()
+		return fmt.Errorf("create span processor failed: %w", err)
+	}
+
+	labels := []label.KeyValue{semconv.ServiceNameKey.String(option.ServiceName)}
+	for key, value := range option.Labels {
+		labels = append(labels, label.String(key, value))
+	}
+	newProvider, err := sdktrace.NewProvider(
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentSample(sdktrace.ProbabilitySampler(option.SampleRatio)),
+		}),
+		sdktrace.WithResource(resource.New(labels...)),
+	)
+	if err != nil {
+		newProcessor.Shutdown()
+		_ = newExporter.Stop()
+		return fmt.Errorf("create trace provider failed: %w", err)
+	}
+	newProvider.RegisterSpanProcessor(newProcessor)
+
+	Shutdown()
+	mu.Lock()
+	provider, processor, exporter = newProvider, newProcessor, newExporter
+	mu.Unlock()
+	global.SetTraceProvider(newProvider)
+	return nil
+}
+
+// Shutdown flush spans in buffer and close connection to collector
+func Shutdown() {
+	mu.Lock()
+	defer mu.Unlock()
+	if provider == nil {
+		return
+	}
+	// unregister shutdown processor, which exports all buffered spans
+	provider.UnregisterSpanProcessor(processor)
+	_ = exporter.Stop()
+	provider, processor, exporter = nil, nil, nil
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+var (
+	defaultPoolCollector = NewPoolCollector()
+)
+
+func init() {
+	storage.RegisterCollector(defaultPoolCollector)
+}
+
+// DefaultCollector return PoolCollector of caches created by NewRedisCache and NewRedisClusterCache
+func DefaultCollector() *PoolCollector {
+	return defaultPoolCollector
+}
+
+// PoolStatser is a redis client with connection pool, like *redis.Client and *redis.ClusterClient
+type PoolStatser interface {
+	PoolStats() *redis.PoolStats
+}
+
+// PoolCollector export redis.PoolStats of registered clients, labeled by pool name.
+// Stats of clients with the same name are summed up.
+type PoolCollector struct {
+	mu    sync.RWMutex
+	pools map[PoolStatser]string
+
+	hitsDesc       *prometheus.Desc
+	missesDesc     *prometheus.Desc
+	timeoutsDesc   *prometheus.Desc
+	totalConnsDesc *prometheus.Desc
+	idleConnsDesc  *prometheus.Desc
+	staleConnsDesc *prometheus.Desc
+}
+
+func NewPoolCollector() *PoolCollector {
+	labels := []string{"pool"}
+	return &PoolCollector{
+		pools: make(map[PoolStatser]string),
+		hitsDesc: prometheus.NewDesc("escore_cache_pool_hits_total",
+			"escore cache total count of free connection found in pool", labels, nil),
+		missesDesc: prometheus.NewDesc("escore_cache_pool_misses_total",
+			"escore cache total count of free connection not found in pool", labels, nil),
+		timeoutsDesc: prometheus.NewDesc("escore_cache_pool_timeouts_total",
+			"escore cache total count of timeout waiting for a connection", labels, nil),
+		totalConnsDesc: prometheus.NewDesc("escore_cache_pool_connections",
+			"escore cache connections in pool", labels, nil),
+		idleConnsDesc: prometheus.NewDesc("escore_cache_pool_idle_connections",
+			"escore cache idle connections in pool", labels, nil),
+		staleConnsDesc: prometheus.NewDesc("escore_cache_pool_stale_connections_total",
+			"escore cache total count of stale connections removed from pool", labels, nil),
+	}
+}
+
+// Register export stats of client with name
+func (collector *PoolCollector) Register(name string, client PoolStatser) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.pools[client] = name
+}
+
+// Unregister stop exporting stats of client
+func (collector *PoolCollector) Unregister(client PoolStatser) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	delete(collector.pools, client)
+}
+
+func (collector *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	collector.mu.RLock()
+	pools := make(map[string]*redis.PoolStats)
+	for client, name := range collector.pools {
+		stats := client.PoolStats()
+		if sum, ok := pools[name]; ok {
+			sum.Hits += stats.Hits
+			sum.Misses += stats.Misses
+			sum.Timeouts += stats.Timeouts
+			sum.TotalConns += stats.TotalConns
+			sum.IdleConns += stats.IdleConns
+			sum.StaleConns += stats.StaleConns
+			continue
+		}
+		pools[name] = &redis.PoolStats{}
+		*pools[name] = *stats
+	}
+	collector.mu.RUnlock()
+
+	for name, stats := range pools {
+		ch <- prometheus.MustNewConstMetric(collector.hitsDesc, prometheus.CounterValue,
+			float64(stats.Hits), name)
+		ch <- prometheus.MustNewConstMetric(collector.missesDesc, prometheus.CounterValue,
+			float64(stats.Misses), name)
+		ch <- prometheus.MustNewConstMetric(collector.timeoutsDesc, prometheus.CounterValue,
+			float64(stats.Timeouts), name)
+		ch <- prometheus.MustNewConstMetric(collector.totalConnsDesc, prometheus.GaugeValue,
+			float64(stats.TotalConns), name)
+		ch <- prometheus.MustNewConstMetric(collector.idleConnsDesc, prometheus.GaugeValue,
+			float64(stats.IdleConns), name)
+		ch <- prometheus.MustNewConstMetric(collector.staleConnsDesc, prometheus.CounterValue,
+			float64(stats.StaleConns), name)
+	}
+}
+
+func (collector *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.hitsDesc
+	ch <- collector.missesDesc
+	ch <- collector.timeoutsDesc
+	ch <- collector.totalConnsDesc
+	ch <- collector.idleConnsDesc
+	ch <- collector.staleConnsDesc
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+type testPool redis.PoolStats
+
+func (pool *testPool) PoolStats() *redis.PoolStats {
+	return (*redis.PoolStats)(pool)
+}
+
+func TestPoolCollector(t *testing.T) {
+	collector := NewPoolCollector()
+	a := &testPool{Hits: 3, Misses: 1, TotalConns: 2, IdleConns: 1}
+	collector.Register("a", a)
+	collector.Register("b", &testPool{Timeouts: 1, StaleConns: 4})
+
+	expected := `
+# HELP escore_cache_pool_hits_total escore cache total count of free connection found in pool
+# TYPE escore_cache_pool_hits_total counter
+escore_cache_pool_hits_total{pool="a"} 3
+escore_cache_pool_hits_total{pool="b"} 0
+# HELP escore_cache_pool_connections escore cache connections in pool
+# TYPE escore_cache_pool_connections gauge
+escore_cache_pool_connections{pool="a"} 2
+escore_cache_pool_connections{pool="b"} 0
+# HELP escore_cache_pool_stale_connections_total escore cache total count of stale connections removed from pool
+# TYPE escore_cache_pool_stale_connections_total counter
+escore_cache_pool_stale_connections_total{pool="a"} 0
+escore_cache_pool_stale_connections_total{pool="b"} 4
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "escore_cache_pool_hits_total",
+		"escore_cache_pool_connections", "escore_cache_pool_stale_connections_total"); err != nil {
+		t.Error(err)
+	}
+
+	collector.Unregister(a)
+	if got := testutil.CollectAndCount(collector); got != 6 {
+		t.Errorf("metrics = %d after unregister, want 6", got)
+	}
+}
+
+func TestPoolCollector_SameName(t *testing.T) {
+	collector := NewPoolCollector()
+	a, b := &testPool{Hits: 3, TotalConns: 2}, &testPool{Hits: 1, TotalConns: 1}
+	collector.Register("127.0.0.1:6379", a)
+	collector.Register("127.0.0.1:6379", b)
+
+	expected := `
+# HELP escore_cache_pool_hits_total escore cache total count of free connection found in pool
+# TYPE escore_cache_pool_hits_total counter
+escore_cache_pool_hits_total{pool="127.0.0.1:6379"} 4
+# HELP escore_cache_pool_connections escore cache connections in pool
+# TYPE escore_cache_pool_connections gauge
+escore_cache_pool_connections{pool="127.0.0.1:6379"} 3
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "escore_cache_pool_hits_total",
+		"escore_cache_pool_connections"); err != nil {
+		t.Error(err)
+	}
+
+	// stats of the other client are still exported
+	collector.Unregister(a)
+	expected = `
+# HELP escore_cache_pool_connections escore cache connections in pool
+# TYPE escore_cache_pool_connections gauge
+escore_cache_pool_connections{pool="127.0.0.1:6379"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"escore_cache_pool_connections"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDefaultCollector_Registered(t *testing.T) {
+	for _, collector := range storage.Collectors() {
+		if collector == DefaultCollector() {
+			return
+		}
+	}
+	t.Error("pool collector of cache is not registered to storage")
+}
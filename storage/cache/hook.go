@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/pkg/eslog"
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultSlowThreshold = 100 * time.Millisecond
+
+	// pipelineCommand is the command label of pipelines
+	pipelineCommand = "pipeline"
+)
+
+// getCommands are GET-family commands, whose nil replies are counted as misses
+var getCommands = map[string]bool{
+	"get":    true,
+	"getset": true,
+	"hget":   true,
+	"mget":   true,
+	"hmget":  true,
+}
+
+// Hook is a go-redis hook which records latency, errors, hits and misses, and a span of each command
+type Hook struct {
+	collector     *storage.Collector
+	slowThreshold time.Duration
+}
+
+type hookStateKey struct{}
+
+type hookState struct {
+	// parent is the context of command, which carries log fields of request
+	parent context.Context
+	start  time.Time
+	span   trace.Span
+}
+
+// NewHook create Hook feeding collector, default is storage.DefaultCollector(),
+// commands slower than slowThreshold are logged, zero means DefaultSlowThreshold
+func NewHook(collector *storage.Collector, slowThreshold time.Duration) *Hook {
+	if collector == nil {
+		collector = storage.DefaultCollector()
+	}
+	if slowThreshold <= 0 {
+		slowThreshold = DefaultSlowThreshold
+	}
+	return &Hook{collector: collector, slowThreshold: slowThreshold}
+}
+
+func (hook *Hook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return hook.start(ctx, cmd.Name()), nil
+}
+
+func (hook *Hook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	state, ok := ctx.Value(hookStateKey{}).(*hookState)
+	if !ok {
+		return nil
+	}
+	elapsed := time.Since(state.start)
+	hook.collector.CacheCommandHistogramVector.WithLabelValues(cmd.Name()).Observe(elapsed.Seconds())
+	err := hook.observe(cmd)
+	hook.finish(state, cmd.Name(), elapsed, err)
+	return nil
+}
+
+func (hook *Hook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return hook.start(ctx, pipelineCommand), nil
+}
+
+func (hook *Hook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	state, ok := ctx.Value(hookStateKey{}).(*hookState)
+	if !ok {
+		return nil
+	}
+	elapsed := time.Since(state.start)
+	hook.collector.CacheCommandHistogramVector.WithLabelValues(pipelineCommand).Observe(elapsed.Seconds())
+	var firstErr error
+	for _, cmd := range cmds {
+		if err := hook.observe(cmd); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	state.span.SetAttributes(label.Int("db.redis.num_cmd", len(cmds)))
+	hook.finish(state, pipelineCommand, elapsed, firstErr)
+	return nil
+}
+
+func (hook *Hook) start(ctx context.Context, command string) context.Context {
+	spanCtx, span := global.Tracer(core.TracerName).Start(ctx, "Redis "+command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationKey.String(command)))
+	return context.WithValue(spanCtx, hookStateKey{}, &hookState{parent: ctx, start: time.Now(), span: span})
+}
+
+// observe count error, hit and miss of cmd, and return its error except redis.Nil.
+// CacheErrorCounter is not increased, it's left to callers which surface errors
+func (hook *Hook) observe(cmd redis.Cmder) error {
+	name := cmd.Name()
+	err := cmd.Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		if strings.HasPrefix(err.Error(), "NOSCRIPT") {
+			// redis.Script falls back to EVAL
+			return nil
+		}
+		hook.collector.CacheCommandErrorCounterVector.WithLabelValues(name).Inc()
+		return err
+	}
+	if !getCommands[name] {
+		return nil
+	}
+
+	hits, misses := 1, 0
+	if err != nil {
+		hits, misses = 0, 1
+	} else if sliceCmd, ok := cmd.(*redis.SliceCmd); ok {
+		// mget and hmget reply nil for each missing key
+		hits = 0
+		for _, value := range sliceCmd.Val() {
+			if value == nil {
+				misses++
+			} else {
+				hits++
+			}
+		}
+	}
+	hook.collector.CacheHitCounterVector.WithLabelValues(name).Add(float64(hits))
+	hook.collector.CacheMissCounterVector.WithLabelValues(name).Add(float64(misses))
+	return nil
+}
+
+func (hook *Hook) finish(state *hookState, command string, elapsed time.Duration, err error) {
+	if err != nil {
+		state.span.RecordError(state.parent, err)
+		state.span.SetStatus(codes.Unknown, err.Error())
+	}
+	state.span.End()
+
+	if elapsed >= hook.slowThreshold {
+		eslog.C(state.parent).Warn("slow redis command",
+			eslog.Field("Command", command), eslog.Field("Elapsed", elapsed.String()), eslog.Err(err))
+	}
+}
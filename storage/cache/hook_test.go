@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+)
+
+func TestHook(t *testing.T) {
+	collector := storage.NewCollector()
+	hook := NewHook(collector, 0)
+	ctx := context.Background()
+
+	process := func(cmd redis.Cmder, err error) {
+		hookCtx, _ := hook.BeforeProcess(ctx, cmd)
+		if err != nil {
+			cmd.SetErr(err)
+		}
+		_ = hook.AfterProcess(hookCtx, cmd)
+	}
+	process(redis.NewStringCmd(ctx, "get", "a"), nil)
+	process(redis.NewStringCmd(ctx, "get", "b"), redis.Nil)
+	process(redis.NewStringCmd(ctx, "get", "c"), redis.Nil)
+	process(redis.NewStatusCmd(ctx, "set", "a", "1"), errors.New("READONLY"))
+	process(redis.NewCmd(ctx, "evalsha", "sha", 0), errors.New("NOSCRIPT No matching script"))
+
+	pipeline := []redis.Cmder{redis.NewStringCmd(ctx, "get", "a"), redis.NewIntCmd(ctx, "incr", "b")}
+	pipelineCtx, _ := hook.BeforeProcessPipeline(ctx, pipeline)
+	pipeline[1].SetErr(errors.New("ERR value is not an integer"))
+	_ = hook.AfterProcessPipeline(pipelineCtx, pipeline)
+
+	counters := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"get hits", testutil.ToFloat64(collector.CacheHitCounterVector.WithLabelValues("get")), 2},
+		{"get misses", testutil.ToFloat64(collector.CacheMissCounterVector.WithLabelValues("get")), 2},
+		{"get errors", testutil.ToFloat64(collector.CacheCommandErrorCounterVector.WithLabelValues("get")), 0},
+		{"set errors", testutil.ToFloat64(collector.CacheCommandErrorCounterVector.WithLabelValues("set")), 1},
+		{"evalsha errors", testutil.ToFloat64(collector.CacheCommandErrorCounterVector.WithLabelValues("evalsha")), 0},
+		{"incr errors", testutil.ToFloat64(collector.CacheCommandErrorCounterVector.WithLabelValues("incr")), 1},
+	}
+	for _, tt := range counters {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// get, set, evalsha and pipeline
+	if got := testutil.CollectAndCount(collector.CacheCommandHistogramVector); got != 4 {
+		t.Errorf("latency series = %d, want 4", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/SongOf/edge-storage-core/storage"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -16,6 +17,10 @@ type RedisCache struct {
 type RedisOption struct {
 	Address  string
 	Password string
+	// PoolName is the label of pool stats, default is Address
+	PoolName string
+	// SlowThreshold is the latency to log slow commands, default is DefaultSlowThreshold
+	SlowThreshold time.Duration
 }
 
 func NewRedisCache(option RedisOption) *RedisCache {
@@ -23,13 +28,26 @@ func NewRedisCache(option RedisOption) *RedisCache {
 		Addr:     option.Address,
 		Password: option.Password,
 	}
-	return &RedisCache{rdb: redis.NewClient(&opt)}
+	rc := &RedisCache{rdb: redis.NewClient(&opt)}
+	rc.rdb.AddHook(NewHook(storage.DefaultCollector(), option.SlowThreshold))
+	name := option.PoolName
+	if name == "" {
+		name = option.Address
+	}
+	defaultPoolCollector.Register(name, rc.rdb)
+	return rc
 }
 
 func (rc *RedisCache) GetClient() *redis.Client {
 	return rc.rdb
 }
 
+// Close close connections of client, and stop exporting its pool stats
+func (rc *RedisCache) Close() error {
+	defaultPoolCollector.Unregister(rc.rdb)
+	return rc.rdb.Close()
+}
+
 func (rc *RedisCache) Lock(ctx context.Context, lock, value string, timeout int) error {
 	expiration := time.Duration(timeout) * time.Second
 	if success, err := rc.rdb.SetNX(ctx, lock, value, expiration).Result(); err != nil {
@@ -60,6 +78,10 @@ type RedisClusterOption struct {
 	Addresses []string
 	User      string
 	Password  string
+	// PoolName is the label of pool stats, default is Addresses joined by comma
+	PoolName string
+	// SlowThreshold is the latency to log slow commands, default is DefaultSlowThreshold
+	SlowThreshold time.Duration
 }
 
 type RedisClusterCache struct {
@@ -70,15 +92,26 @@ func NewRedisClusterCache(option RedisClusterOption) *RedisClusterCache {
 	opt := redis.ClusterOptions{
 		Addrs: option.Addresses,
 	}
-	return &RedisClusterCache{
-		rdb: redis.NewClusterClient(&opt),
+	rcc := &RedisClusterCache{rdb: redis.NewClusterClient(&opt)}
+	rcc.rdb.AddHook(NewHook(storage.DefaultCollector(), option.SlowThreshold))
+	name := option.PoolName
+	if name == "" {
+		name = strings.Join(option.Addresses, ",")
 	}
+	defaultPoolCollector.Register(name, rcc.rdb)
+	return rcc
 }
 
 func (rcc *RedisClusterCache) GetClient() *redis.ClusterClient {
 	return rcc.rdb
 }
 
+// Close close connections of client, and stop exporting its pool stats
+func (rcc *RedisClusterCache) Close() error {
+	defaultPoolCollector.Unregister(rcc.rdb)
+	return rcc.rdb.Close()
+}
+
 func (rcc *RedisClusterCache) Lock(ctx context.Context, lock, value string, timeout int) error {
 	expiration := time.Duration(timeout) * time.Second
 	if err := rcc.rdb.SetNX(ctx, lock, value, expiration).Err(); err != nil {
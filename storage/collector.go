@@ -1,15 +1,36 @@
 package storage
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
 
 var (
 	defaultCollector = NewCollector()
+
+	collectorsMu sync.RWMutex
+	// collectors are registered by storage packages linked into binary, like pool stats of cache and database
+	collectors []prometheus.Collector
 )
 
-func DefaultCollector() prometheus.Collector {
+func DefaultCollector() *Collector {
 	return defaultCollector
 }
 
+// RegisterCollector add collector of storage package to Collectors, it's called by init of the package
+func RegisterCollector(collector prometheus.Collector) {
+	collectorsMu.Lock()
+	defer collectorsMu.Unlock()
+	collectors = append(collectors, collector)
+}
+
+// Collectors return DefaultCollector and collectors registered by storage packages
+func Collectors() []prometheus.Collector {
+	collectorsMu.RLock()
+	defer collectorsMu.RUnlock()
+	return append([]prometheus.Collector{defaultCollector}, collectors...)
+}
+
 func NewCollector() *Collector {
 	databaseErrorCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "escore_database_error_total",
@@ -21,9 +42,53 @@ func NewCollector() *Collector {
 		Help: "escore cache error total count",
 	})
 
+	databaseQueryHistogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "escore_database_query_seconds",
+		Help:    "escore database query latency seconds",
+		Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+	}, []string{"operation", "table"})
+
+	databaseQueryErrorCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "escore_database_query_error_total",
+		Help: "escore database query error count, record not found is excluded",
+	}, []string{"operation", "table"})
+
+	databaseNotFoundCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "escore_database_record_not_found_total",
+		Help: "escore database query record not found count",
+	}, []string{"operation", "table"})
+
+	cacheCommandHistogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "escore_cache_command_seconds",
+		Help:    "escore cache command latency seconds",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	}, []string{"command"})
+
+	cacheCommandErrorCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "escore_cache_command_error_total",
+		Help: "escore cache command error count, redis.Nil is excluded",
+	}, []string{"command"})
+
+	cacheHitCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "escore_cache_hit_total",
+		Help: "escore cache keys found by GET-family commands count",
+	}, []string{"command"})
+
+	cacheMissCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "escore_cache_miss_total",
+		Help: "escore cache keys not found by GET-family commands count",
+	}, []string{"command"})
+
 	return &Collector{
-		DatabaseErrorCounter: databaseErrorCounter,
-		CacheErrorCounter:    cacheErrorCounter,
+		DatabaseErrorCounter:                databaseErrorCounter,
+		CacheErrorCounter:                   cacheErrorCounter,
+		DatabaseQueryHistogramVector:        databaseQueryHistogramVec,
+		DatabaseQueryErrorCounterVector:     databaseQueryErrorCounterVec,
+		DatabaseRecordNotFoundCounterVector: databaseNotFoundCounterVec,
+		CacheCommandHistogramVector:         cacheCommandHistogramVec,
+		CacheCommandErrorCounterVector:      cacheCommandErrorCounterVec,
+		CacheHitCounterVector:               cacheHitCounterVec,
+		CacheMissCounterVector:              cacheMissCounterVec,
 	}
 }
 
@@ -38,6 +103,15 @@ func CacheErrorInc() {
 type Collector struct {
 	DatabaseErrorCounter prometheus.Counter
 	CacheErrorCounter    prometheus.Counter
+	// database queries are labeled by operation and table
+	DatabaseQueryHistogramVector        *prometheus.HistogramVec
+	DatabaseQueryErrorCounterVector     *prometheus.CounterVec
+	DatabaseRecordNotFoundCounterVector *prometheus.CounterVec
+	// cache commands are labeled by command name
+	CacheCommandHistogramVector    *prometheus.HistogramVec
+	CacheCommandErrorCounterVector *prometheus.CounterVec
+	CacheHitCounterVector          *prometheus.CounterVec
+	CacheMissCounterVector         *prometheus.CounterVec
 }
 
 func (collector *Collector) CacheErrorInc() {
@@ -51,9 +125,23 @@ func (collector *Collector) DatabaseErrorInc() {
 func (collector *Collector) Collect(ch chan<- prometheus.Metric) {
 	collector.DatabaseErrorCounter.Collect(ch)
 	collector.CacheErrorCounter.Collect(ch)
+	collector.DatabaseQueryHistogramVector.Collect(ch)
+	collector.DatabaseQueryErrorCounterVector.Collect(ch)
+	collector.DatabaseRecordNotFoundCounterVector.Collect(ch)
+	collector.CacheCommandHistogramVector.Collect(ch)
+	collector.CacheCommandErrorCounterVector.Collect(ch)
+	collector.CacheHitCounterVector.Collect(ch)
+	collector.CacheMissCounterVector.Collect(ch)
 }
 
 func (collector *Collector) Describe(ch chan<- *prometheus.Desc) {
 	collector.DatabaseErrorCounter.Describe(ch)
 	collector.CacheErrorCounter.Describe(ch)
+	collector.DatabaseQueryHistogramVector.Describe(ch)
+	collector.DatabaseQueryErrorCounterVector.Describe(ch)
+	collector.DatabaseRecordNotFoundCounterVector.Describe(ch)
+	collector.CacheCommandHistogramVector.Describe(ch)
+	collector.CacheCommandErrorCounterVector.Describe(ch)
+	collector.CacheHitCounterVector.Describe(ch)
+	collector.CacheMissCounterVector.Describe(ch)
 }
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+var (
+	defaultPoolCollector = NewPoolCollector()
+)
+
+func init() {
+	storage.RegisterCollector(defaultPoolCollector)
+}
+
+// DefaultCollector return PoolCollector of databases initialized by Init
+func DefaultCollector() *PoolCollector {
+	return defaultPoolCollector
+}
+
+// PoolCollector export sql.DBStats of registered connection pools, labeled by pool name
+type PoolCollector struct {
+	mu    sync.RWMutex
+	pools map[string]*sql.DB
+
+	maxOpenDesc           *prometheus.Desc
+	openDesc              *prometheus.Desc
+	inUseDesc             *prometheus.Desc
+	idleDesc              *prometheus.Desc
+	waitCountDesc         *prometheus.Desc
+	waitDurationDesc      *prometheus.Desc
+	maxIdleClosedDesc     *prometheus.Desc
+	maxLifetimeClosedDesc *prometheus.Desc
+}
+
+func NewPoolCollector() *PoolCollector {
+	labels := []string{"pool"}
+	return &PoolCollector{
+		pools: make(map[string]*sql.DB),
+		maxOpenDesc: prometheus.NewDesc("escore_database_max_open_connections",
+			"escore database max open connections", labels, nil),
+		openDesc: prometheus.NewDesc("escore_database_open_connections",
+			"escore database open connections, both in use and idle", labels, nil),
+		inUseDesc: prometheus.NewDesc("escore_database_in_use_connections",
+			"escore database connections in use", labels, nil),
+		idleDesc: prometheus.NewDesc("escore_database_idle_connections",
+			"escore database idle connections", labels, nil),
+		waitCountDesc: prometheus.NewDesc("escore_database_wait_total",
+			"escore database total count of waiting for a connection", labels, nil),
+		waitDurationDesc: prometheus.NewDesc("escore_database_wait_duration_seconds_total",
+			"escore database total seconds blocked waiting for a connection", labels, nil),
+		maxIdleClosedDesc: prometheus.NewDesc("escore_database_max_idle_closed_total",
+			"escore database total count of connections closed due to max idle connections", labels, nil),
+		maxLifetimeClosedDesc: prometheus.NewDesc("escore_database_max_lifetime_closed_total",
+			"escore database total count of connections closed due to max connection lifetime", labels, nil),
+	}
+}
+
+// Register export stats of pool with name, the previous pool with the same name is replaced
+func (collector *PoolCollector) Register(name string, pool *sql.DB) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.pools[name] = pool
+}
+
+// Unregister stop exporting stats of pool with name
+func (collector *PoolCollector) Unregister(name string) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	delete(collector.pools, name)
+}
+
+func (collector *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	collector.mu.RLock()
+	defer collector.mu.RUnlock()
+	for name, pool := range collector.pools {
+		stats := pool.Stats()
+		ch <- prometheus.MustNewConstMetric(collector.maxOpenDesc, prometheus.GaugeValue,
+			float64(stats.MaxOpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(collector.openDesc, prometheus.GaugeValue,
+			float64(stats.OpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(collector.inUseDesc, prometheus.GaugeValue,
+			float64(stats.InUse), name)
+		ch <- prometheus.MustNewConstMetric(collector.idleDesc, prometheus.GaugeValue,
+			float64(stats.Idle), name)
+		ch <- prometheus.MustNewConstMetric(collector.waitCountDesc, prometheus.CounterValue,
+			float64(stats.WaitCount), name)
+		ch <- prometheus.MustNewConstMetric(collector.waitDurationDesc, prometheus.CounterValue,
+			stats.WaitDuration.Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(collector.maxIdleClosedDesc, prometheus.CounterValue,
+			float64(stats.MaxIdleClosed), name)
+		ch <- prometheus.MustNewConstMetric(collector.maxLifetimeClosedDesc, prometheus.CounterValue,
+			float64(stats.MaxLifetimeClosed), name)
+	}
+}
+
+func (collector *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.maxOpenDesc
+	ch <- collector.openDesc
+	ch <- collector.inUseDesc
+	ch <- collector.idleDesc
+	ch <- collector.waitCountDesc
+	ch <- collector.waitDurationDesc
+	ch <- collector.maxIdleClosedDesc
+	ch <- collector.maxLifetimeClosedDesc
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+func TestPoolCollector(t *testing.T) {
+	// connections are opened lazily, so no server is needed
+	pool, err := sql.Open("mysql", option.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	pool.SetMaxOpenConns(10)
+
+	collector := NewPoolCollector()
+	collector.Register("test", pool)
+	expected := `
+# HELP escore_database_max_open_connections escore database max open connections
+# TYPE escore_database_max_open_connections gauge
+escore_database_max_open_connections{pool="test"} 10
+# HELP escore_database_open_connections escore database open connections, both in use and idle
+# TYPE escore_database_open_connections gauge
+escore_database_open_connections{pool="test"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"escore_database_max_open_connections", "escore_database_open_connections"); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(collector); got != 8 {
+		t.Errorf("metrics = %d, want 8", got)
+	}
+
+	collector.Unregister("test")
+	if got := testutil.CollectAndCount(collector); got != 0 {
+		t.Errorf("metrics = %d after unregister, want 0", got)
+	}
+}
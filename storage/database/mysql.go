@@ -65,6 +65,10 @@ func Init(option MySQLOption, p logger.Interface) {
 		panic(err)
 	}
 
+	if err := gormDB.Use(NewPlugin(storage.DefaultCollector())); err != nil {
+		panic(err)
+	}
+
 	sqlDB, err := gormDB.DB()
 	if err != nil {
 		storage.DatabaseErrorInc()
@@ -88,6 +92,7 @@ func Init(option MySQLOption, p logger.Interface) {
 		}
 	}
 	sqlDB.SetConnMaxLifetime(connMaxLifetime)
+	defaultPoolCollector.Register(option.Database, sqlDB)
 
 	db = &Database{
 		Option: &option,
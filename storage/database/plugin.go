@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/SongOf/edge-storage-core/core"
+	"github.com/SongOf/edge-storage-core/storage"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+	"gorm.io/gorm"
+	"time"
+)
+
+// operations of queries, which label metrics and spans
+const (
+	OperationCreate = "create"
+	OperationQuery  = "query"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+	OperationRaw    = "raw"
+)
+
+const (
+	pluginName    = "es:observer"
+	queryStateKey = "es:query_state"
+	unknownTable  = "unknown"
+)
+
+// Plugin is a gorm plugin which records a span and metrics of each query
+type Plugin struct {
+	collector *storage.Collector
+}
+
+type queryState struct {
+	ctx       context.Context
+	operation string
+	start     time.Time
+	span      trace.Span
+}
+
+// NewPlugin create Plugin feeding collector, default is storage.DefaultCollector()
+func NewPlugin(collector *storage.Collector) *Plugin {
+	if collector == nil {
+		collector = storage.DefaultCollector()
+	}
+	return &Plugin{collector: collector}
+}
+
+func (plugin *Plugin) Name() string {
+	return pluginName
+}
+
+func (plugin *Plugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	errs := []error{
+		callback.Create().Before("*").Register("es:before_create", plugin.before(OperationCreate)),
+		callback.Create().After("*").Register("es:after_create", plugin.after),
+		callback.Query().Before("*").Register("es:before_query", plugin.before(OperationQuery)),
+		callback.Query().After("*").Register("es:after_query", plugin.after),
+		callback.Update().Before("*").Register("es:before_update", plugin.before(OperationUpdate)),
+		callback.Update().After("*").Register("es:after_update", plugin.after),
+		callback.Delete().Before("*").Register("es:before_delete", plugin.before(OperationDelete)),
+		callback.Delete().After("*").Register("es:after_delete", plugin.after),
+		callback.Row().Before("*").Register("es:before_row", plugin.before(OperationQuery)),
+		callback.Row().After("*").Register("es:after_row", plugin.after),
+		callback.Raw().Before("*").Register("es:before_raw", plugin.before(OperationRaw)),
+		callback.Raw().After("*").Register("es:after_raw", plugin.after),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (plugin *Plugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		op := operation
+		if op == OperationQuery && db.Statement.SQL.Len() > 0 {
+			// SQL is built by db.Raw()
+			op = OperationRaw
+		}
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		_, span := global.Tracer(core.TracerName).Start(ctx, "Database "+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperationKey.String(op)))
+		db.InstanceSet(queryStateKey, &queryState{ctx: ctx, operation: op, start: time.Now(), span: span})
+	}
+}
+
+func (plugin *Plugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(queryStateKey)
+	if !ok {
+		return
+	}
+	state := value.(*queryState)
+	table := db.Statement.Table
+	if table == "" {
+		table = unknownTable
+	}
+
+	plugin.collector.DatabaseQueryHistogramVector.WithLabelValues(state.operation, table).
+		Observe(time.Since(state.start).Seconds())
+	switch err := db.Error; {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		plugin.collector.DatabaseRecordNotFoundCounterVector.WithLabelValues(state.operation, table).Inc()
+	case err != nil:
+		plugin.collector.DatabaseQueryErrorCounterVector.WithLabelValues(state.operation, table).Inc()
+		plugin.collector.DatabaseErrorInc()
+		state.span.RecordError(state.ctx, err)
+		state.span.SetStatus(codes.Unknown, err.Error())
+	}
+
+	state.span.SetAttributes(label.String("db.table", table), semconv.DBStatementKey.String(db.Statement.SQL.String()))
+	state.span.End()
+}
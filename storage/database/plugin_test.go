@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"github.com/SongOf/edge-storage-core/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type testUser struct {
+	ID   int
+	Name string
+}
+
+// dryRunConn is a connection which is never used in dry run, it doesn't ping server when gorm is opened
+type dryRunConn struct {
+	gorm.ConnPool
+}
+
+func TestPlugin(t *testing.T) {
+	// statements are built but not executed in dry run, so no server is needed
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: dryRunConn{}, SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := storage.NewCollector()
+	if err := db.Use(NewPlugin(collector)); err != nil {
+		t.Fatal(err)
+	}
+	// inject error of query
+	err = db.Callback().Query().Before("gorm:query").Register("test:error", func(db *gorm.DB) {
+		if err, ok := db.Get("test:error"); ok {
+			_ = db.AddError(err.(error))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Create(&testUser{Name: "a"})
+	db.Where("name = ?", "a").Find(&[]testUser{})
+	db.Model(&testUser{ID: 1}).Update("name", "b")
+	db.Delete(&testUser{ID: 1})
+	db.Exec("UPDATE test_users SET name = ?", "c")
+	db.Set("test:error", gorm.ErrRecordNotFound).First(&testUser{})
+	db.Set("test:error", errors.New("connection refused")).First(&testUser{})
+
+	tests := []struct {
+		operation, table string
+	}{
+		{OperationCreate, "test_users"},
+		{OperationQuery, "test_users"},
+		{OperationUpdate, "test_users"},
+		{OperationDelete, "test_users"},
+		{OperationRaw, unknownTable},
+	}
+	if got := testutil.CollectAndCount(collector.DatabaseQueryHistogramVector); got != len(tests) {
+		t.Errorf("histogram series = %d, want %d", got, len(tests))
+	}
+	for _, tt := range tests {
+		// deleting a series reports whether it exists
+		if !collector.DatabaseQueryHistogramVector.DeleteLabelValues(tt.operation, tt.table) {
+			t.Errorf("histogram of %s %s not found", tt.operation, tt.table)
+		}
+	}
+
+	if got := testutil.ToFloat64(collector.DatabaseRecordNotFoundCounterVector.WithLabelValues(
+		OperationQuery, "test_users")); got != 1 {
+		t.Errorf("record not found count = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(collector.DatabaseQueryErrorCounterVector.WithLabelValues(
+		OperationQuery, "test_users")); got != 1 {
+		t.Errorf("query error count = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(collector.DatabaseErrorCounter); got != 1 {
+		t.Errorf("database error count = %v, want 1", got)
+	}
+}